@@ -8,6 +8,7 @@ import (
 	"hawker-backend/conf"
 	"hawker-backend/database"
 	"hawker-backend/handlers"
+	"hawker-backend/logic"
 	"hawker-backend/middleware"
 	"hawker-backend/models"
 	"hawker-backend/repositories"
@@ -16,6 +17,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -51,30 +53,97 @@ func main() {
 	productRepo := repositories.NewProductRepository(db)
 	categoryRepo := repositories.NewCategoryRepository(db)
 	introRepository := repositories.NewMemIntroRepository()
+	hawkingSessionRepo := repositories.NewHawkingSessionRepository(db)
+	promotionRepo := repositories.NewPromotionRepository(db)
+	zoneRepo := repositories.NewZoneRepository(db)
+	announcementRepo := repositories.NewAnnouncementRepository(db)
+	programRepo := repositories.NewProgramRepository(db)
+	storeGrantRepo := repositories.NewStoreGrantRepository(db)
+	playLogRepo := repositories.NewPlayLogRepository(db)
 
 	// 初始化语音服务
-	audioService := services.NewDoubaoAudioService(
+	doubaoService := services.NewDoubaoAudioService(
 		cfg.Volcengine.AppID,
 		cfg.Volcengine.AccessToken,
 		cfg.Volcengine.ClusterID,
 		//cfg.Volcengine.VoiceType, // 建议用 "zh_male_shuangkuai_ads" 或 "zh_female_shuangkuai_ads"
 		cfg.Server.StaticDir,
 	)
+	// 所有打到火山引擎的请求共用一个令牌桶
+	audioService := services.NewRateLimitedAudioService(doubaoService, cfg.Synthesis.RatePerSecond, cfg.Synthesis.Burst)
+
+	// 全局合成执行器：所有 Session 共享，限制并发并在门店之间公平调度
+	synthesisExecutor := services.NewSynthesisExecutor(cfg.Synthesis.Workers, services.RetryPolicy{
+		MaxAttempts: cfg.Synthesis.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.Synthesis.RetryBaseSec) * time.Second,
+		MaxDelay:    time.Duration(cfg.Synthesis.RetryMaxSec) * time.Second,
+		Timeout:     time.Duration(cfg.Synthesis.TimeoutSec) * time.Second,
+	})
+	synthesisExecutor.Start()
 
 	hub := services.NewHub()
+	hub.SetKeepalive(services.Keepalive{
+		PingInterval:   time.Duration(cfg.Hub.PingIntervalSec) * time.Second,
+		PongWait:       time.Duration(cfg.Hub.PongTimeoutSec) * time.Second,
+		WriteWait:      time.Duration(cfg.Hub.WriteTimeoutSec) * time.Second,
+		MaxMessageSize: cfg.Hub.MaxMessageBytes,
+	})
+	// 多实例部署：通过 Postgres LISTEN/NOTIFY 把广播同步到其它实例的客户端
+	if cfg.Hub.Backplane == "postgres" {
+		dsn := database.DSN(dbHost, dbPort, dbUser, dbPass, dbName)
+		hub.UseBackplane(context.Background(), services.NewPostgresBackplane(db, dsn, cfg.Hub.Channel))
+	}
 	go hub.Run()
 
 	// 注入调度器
-	scheduler := services.NewHawkingScheduler(productRepo, introRepository, audioService, hub)
+	scheduler := services.NewHawkingScheduler(productRepo, introRepository, hawkingSessionRepo, audioService, synthesisExecutor, hub)
+
+	// 队列合成模式：多个实例通过数据库队列分摊 TTS，音频写入共享的 static 目录
+	if cfg.Synthesis.Mode == "queue" {
+		scheduler.EnableSynthesisQueue()
+		if cfg.Synthesis.QueueWorkers > 0 {
+			services.NewQueueWorker(productRepo, hawkingSessionRepo, audioService,
+				time.Duration(cfg.Synthesis.TimeoutSec)*time.Second,
+				time.Duration(cfg.Synthesis.QueuePollMs)*time.Millisecond,
+			).Start(cfg.Synthesis.QueueWorkers)
+		}
+	}
+
+	// 库存规则：根据库存自动切换清货/引流模式，默认关闭，在配置里开启 stock_rules.enabled
+	var stockRules *services.StockRuleEngine
+	if cfg.StockRules.Enabled {
+		stockRules = services.NewStockRuleEngine(logic.StockRule{
+			LowStockRatio: cfg.StockRules.LowStockRatio,
+			AbundantRatio: cfg.StockRules.AbundantRatio,
+		}, productRepo, scheduler)
+	}
 
 	// 初始化 Handlers (注入 Repo)
-	productHandler := handlers.NewProductHandler(productRepo, scheduler)
+	productHandler := handlers.NewProductHandler(productRepo, promotionRepo, zoneRepo, scheduler, stockRules)
 	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
+	zoneHandler := handlers.NewZoneHandler(zoneRepo, scheduler)
+	// 临时广播与叫卖共用合成执行器，但以紧急工作插队
+	announcer := services.NewAnnouncer(announcementRepo, audioService, synthesisExecutor, scheduler)
+	announcementHandler := handlers.NewAnnouncementHandler(announcementRepo, zoneRepo, storeGrantRepo, announcer)
+	programRunner := services.NewProgramRunner(programRepo, productRepo, scheduler)
+	programHandler := handlers.NewProgramHandler(programRepo, zoneRepo, storeGrantRepo, programRunner)
 
 	setupAndPrewarmIntros(introRepository, audioService)
 
+	// 开场白预热完成后再恢复 Session，保证恢复出来的任务能拿到完整的开场白池
+	if err := scheduler.Restore(); err != nil {
+		log.Printf("❌ 恢复叫卖会话失败: %v", err)
+	}
+	go scheduler.RunHousekeeping()
+	go programRunner.Run()
+
 	authHandler := handlers.NewAuthHandler(db, cfg.Auth)
 	storeHandler := handlers.NewStoreHandler(db)
+	storeGrantHandler := handlers.NewStoreGrantHandler(storeGrantRepo)
+	playLogHandler := handlers.NewPlayLogHandler(playLogRepo, storeGrantRepo)
+	wsHandler := handlers.NewWsHandler(hub, storeGrantRepo, cfg.Auth.JWTSecret)
+	// WebSocket 命令与 REST 接口共用叫卖任务的处理逻辑
+	hub.SetCommandHandler(handlers.NewWsCommandHandler(productHandler, playLogHandler, storeGrantRepo))
 
 	// 3. 注册路由
 	r := gin.Default()
@@ -84,6 +153,8 @@ func main() {
 	{
 		public.POST("/register", authHandler.Register)
 		public.POST("/login", authHandler.Login)
+		// WebSocket 自行鉴权：浏览器和音箱无法在握手时设置 Authorization 头，令牌也可以走子协议或查询参数
+		public.GET("/ws", wsHandler.ServeWs)
 	}
 	// 需要鉴权的路由
 	protected := r.Group("/api/v1")
@@ -93,12 +164,32 @@ func main() {
 		protected.POST("/products", productHandler.CreateProduct)
 		protected.GET("/products", productHandler.GetProducts)
 		//v1.PATCH("/products/:id/hawking", productHandler.UpdateHawkingConfig)
-		// 叫卖任务管理
-		protected.POST("/hawking/tasks", productHandler.AddHawkingTaskHandler)          // 添加任务
-		protected.DELETE("/hawking/tasks/:id", productHandler.RemoveHawkingTaskHandler) // 移除任务
-		protected.GET("/hawking/tasks", productHandler.GetHawkingTasksHandler)
+		// 叫卖任务管理：门店在查询参数或请求体的 store_id 里，先校验门店权限
+		hawking := protected.Group("/hawking", middleware.HawkingStoreAccess(storeGrantRepo))
+		{
+			hawking.POST("/tasks", productHandler.AddHawkingTaskHandler)          // 添加任务
+			hawking.DELETE("/tasks/:id", productHandler.RemoveHawkingTaskHandler) // 移除任务
+			hawking.GET("/tasks", productHandler.GetHawkingTasksHandler)
+			hawking.GET("/tasks/changes", productHandler.GetTaskChangesHandler)         // 断线重连后增量同步
+			hawking.PUT("/tasks", productHandler.ReplaceTasksHandler)                   // 整体替换任务
+			hawking.DELETE("/tasks", productHandler.ClearTasksHandler)                  // 清空会话
+			hawking.POST("/tasks/batch", productHandler.BatchAddTasksHandler)           // 批量添加
+			hawking.POST("/tasks/batch-remove", productHandler.BatchRemoveTasksHandler) // 批量移除
+			hawking.PUT("/tasks/order", productHandler.ReorderTasksHandler)             // 调整任务顺序
+			hawking.POST("/session/pause", productHandler.PauseSessionHandler)          // 暂停叫卖，保留任务
+			hawking.POST("/session/resume", productHandler.ResumeSessionHandler)        // 恢复叫卖
+			hawking.POST("/session/stop", productHandler.StopSessionHandler)            // 停止叫卖并销毁会话
+			hawking.POST("/scripts/preview", productHandler.PreviewScriptsHandler)      // 候选文案预览，不合成
+			hawking.POST("/switch-voice", productHandler.SwitchVoiceHandler)            // 切换音色
+			hawking.PUT("/tasks/:id/voice", productHandler.SetTaskVoiceHandler)         // 单个任务固定音色
+			hawking.POST("/promotions/:id", productHandler.LoadPromotionHandler)        // 促销场次一键导入叫卖
+			hawking.POST("/announcements", announcementHandler.CreateAnnouncement)      // 插播临时广播
+		}
 		protected.POST("/hawking/intro", productHandler.SyncIntroHandler)
-		protected.POST("hawking/switch-voice", productHandler.SwitchVoiceHandler) // 切换音色
+		// 按 ID 操作的广播和节目，由 handler 查出记录后校验所属门店
+		protected.DELETE("/hawking/announcements/:id", announcementHandler.CancelAnnouncement) // 停止重复播放
+		protected.PUT("/hawking/programs/:id", programHandler.UpdateProgram)                   // 修改叫卖节目
+		protected.DELETE("/hawking/programs/:id", programHandler.DeleteProgram)                // 删除叫卖节目
 		//v1.GET("/hawking/intros", productHandler.SyncIntroHandler) // 根据音色和时间点获取到开场白池
 
 		// Category 路由
@@ -107,21 +198,29 @@ func main() {
 		// 门店管理
 		protected.GET("/stores", storeHandler.GetMyStores)
 		protected.POST("/store", storeHandler.CreateStore)
-		protected.GET("/stores/:id/categories", storeHandler.GetCategories)
-		protected.GET("/stores/:id/products", productHandler.GetProducts)
-		protected.GET("/stores/:id/revenues", storeHandler.GetRevenues)
-		protected.GET("/stores/:id/dependencies", productHandler.GetDependencies)
-		protected.GET("stores/:id/promotions", storeHandler.GetPromotions)
+		// 门店下的接口只有店主本人和被授权的店员能访问
+		store := protected.Group("/stores/:id", middleware.StoreAccess(storeGrantRepo))
+		{
+			store.GET("/categories", storeHandler.GetCategories)
+			store.GET("/products", productHandler.GetProducts)
+			store.GET("/revenues", storeHandler.GetRevenues)
+			store.GET("/dependencies", productHandler.GetDependencies)
+			store.GET("/promotions", storeHandler.GetPromotions)
+			store.GET("/zones", zoneHandler.GetZones)    // 播放分区列表
+			store.POST("/zones", zoneHandler.CreateZone) // 新建播放分区
+			store.GET("/announcements", announcementHandler.GetAnnouncements)
+			store.GET("/programs", programHandler.GetPrograms)
+			store.POST("/programs", programHandler.CreateProgram)
+			store.GET("/grants", storeGrantHandler.GetGrants)                // 店员与设备授权
+			store.POST("/grants", storeGrantHandler.CreateGrant)             // 授权店员或生成设备令牌
+			store.DELETE("/grants/:grant_id", storeGrantHandler.RevokeGrant) // 吊销授权
+			store.GET("/plays/stats", playLogHandler.GetPlayStats)           // 按天的播放次数与时长
+		}
 		protected.POST("/stores/categories/sync", categoryHandler.SyncCategoriesHandler)
 		protected.POST("/stores/products/sync", productHandler.SyncProductsHandler)
 		protected.POST("/stores/products-dependency/sync", productHandler.SyncDependenciesHandler)
 		protected.POST("/stores/revenues/sync", storeHandler.SyncRevenuesHandler)
 		protected.POST("/stores/promotions/sync", storeHandler.SyncPromotionsHandler)
-
-		// 3. 注册 WebSocket 路由
-		protected.GET("/ws", func(c *gin.Context) {
-			handlers.ServeWs(hub, c)
-		})
 	}
 	_ = r.Run(fmt.Sprintf(":%d", cfg.Server.Port))
 }
@@ -10,6 +10,9 @@ type Config struct {
 	Server     ServerConfig     `mapstructure:"server"`
 	Database   DatabaseConfig   `mapstructure:"database"`
 	Volcengine VolcengineConfig `mapstructure:"volcengine"`
+	Synthesis  SynthesisConfig  `mapstructure:"synthesis"`
+	StockRules StockRuleConfig  `mapstructure:"stock_rules"`
+	Hub        HubConfig        `mapstructure:"hub"`
 
 	Auth AuthConfig `mapstructure:"auth"`
 }
@@ -40,11 +43,70 @@ type VolcengineConfig struct {
 	VoiceType   string `mapstructure:"voice_type"`
 }
 
+// SynthesisConfig 语音合成的并发与限流
+type SynthesisConfig struct {
+	Workers       int     `mapstructure:"workers"`         // 全局合成并发数
+	RatePerSecond float64 `mapstructure:"rate_per_second"` // 每秒最多向供应商发起的请求数，<=0 表示不限流
+	Burst         int     `mapstructure:"burst"`           // 令牌桶容量，允许的瞬时突发请求数
+
+	MaxAttempts  int `mapstructure:"max_attempts"`   // 单个任务最多尝试合成的次数
+	RetryBaseSec int `mapstructure:"retry_base_sec"` // 第一次重试的等待秒数，之后指数退避
+	RetryMaxSec  int `mapstructure:"retry_max_sec"`  // 退避等待的上限秒数
+	TimeoutSec   int `mapstructure:"timeout_sec"`    // 单次合成的超时秒数
+
+	// Mode 合成方式：
+	//   - local：每个实例自己调用 TTS（默认）
+	//   - queue：通过数据库队列领取合成工作，多个实例共享 static 目录、分摊合成，不会重复合成
+	Mode         string `mapstructure:"mode"`
+	QueueWorkers int    `mapstructure:"queue_workers"` // queue 模式下本实例领取队列的 worker 数，0 表示只投递不消费
+	QueuePollMs  int    `mapstructure:"queue_poll_ms"` // 队列为空时的轮询间隔（毫秒）
+}
+
+// StockRuleConfig 根据库存自动切换叫卖模式的阈值，均以安全库存的倍数表示
+type StockRuleConfig struct {
+	Enabled       bool    `mapstructure:"enabled"`         // 开启后同步商品时按库存改写 hawking_mode，默认关闭
+	LowStockRatio float64 `mapstructure:"low_stock_ratio"` // 当前库存 <= 安全库存 × 该值时进入清货模式
+	AbundantRatio float64 `mapstructure:"abundant_ratio"`  // 当前库存 >= 安全库存 × 该值时进入货源充足模式
+}
+
+// HubConfig WebSocket 广播配置
+type HubConfig struct {
+	// Backplane 多实例之间的消息总线：为空表示单实例；postgres 表示使用 LISTEN/NOTIFY
+	Backplane string `mapstructure:"backplane"`
+	Channel   string `mapstructure:"channel"` // NOTIFY 的频道名，同一套部署的所有实例必须一致
+
+	// 连接保活：每隔 PingIntervalSec 发一次 ping，PongTimeoutSec 内没有收到任何数据（包括 pong）即判定断线
+	PingIntervalSec int   `mapstructure:"ping_interval_sec"`
+	PongTimeoutSec  int   `mapstructure:"pong_timeout_sec"`
+	WriteTimeoutSec int   `mapstructure:"write_timeout_sec"` // 单次写超时，写不出去的连接直接断开
+	MaxMessageBytes int64 `mapstructure:"max_message_bytes"` // 客户端上行消息的大小上限
+}
+
 // LoadConfig 解析配置文件
 func LoadConfig(path string) (*Config, error) {
 	viper.SetConfigFile(path)
 	viper.SetConfigType("yaml")
 
+	// 默认值：配置文件中没写时生效
+	viper.SetDefault("synthesis.workers", 4)
+	viper.SetDefault("synthesis.rate_per_second", 5)
+	viper.SetDefault("synthesis.burst", 5)
+	viper.SetDefault("synthesis.max_attempts", 5)
+	viper.SetDefault("synthesis.retry_base_sec", 2)
+	viper.SetDefault("synthesis.retry_max_sec", 60)
+	viper.SetDefault("synthesis.timeout_sec", 30)
+	viper.SetDefault("hub.channel", "hawking_hub")
+	viper.SetDefault("hub.ping_interval_sec", 25)
+	viper.SetDefault("hub.pong_timeout_sec", 60)
+	viper.SetDefault("hub.write_timeout_sec", 10)
+	viper.SetDefault("hub.max_message_bytes", 64*1024)
+	viper.SetDefault("synthesis.mode", "local")
+	viper.SetDefault("synthesis.queue_workers", 2)
+	viper.SetDefault("synthesis.queue_poll_ms", 1000)
+	viper.SetDefault("stock_rules.enabled", false) // 会改写商品的叫卖模式，默认关闭，需要时在配置里开启
+	viper.SetDefault("stock_rules.low_stock_ratio", 1.0)
+	viper.SetDefault("stock_rules.abundant_ratio", 3.0)
+
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("读取配置文件失败: %v", err)
 	}
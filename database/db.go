@@ -8,14 +8,19 @@ import (
 	"gorm.io/gorm"
 )
 
+// DSN 拼接 Postgres 连接串，GORM 和消息总线的监听连接共用
+func DSN(host, port, user, password, dbname string) string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", host, user, password, dbname, port, "disable")
+}
+
 func InitDB(host, port, user, password, dbname string) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", host, user, password, dbname, port, "disable")
+	dsn := DSN(host, port, user, password, dbname)
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		// 可以在这里关闭外键约束检查（如果迁移遇到循环依赖报错的话）
 		// DisableForeignKeyConstraintWhenMigrating: true,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("数据库连接失败: " + err.Error())
+		return nil, fmt.Errorf("数据库连接失败: %w", err)
 	}
 
 	// 先确保扩展开启
@@ -31,9 +36,17 @@ func InitDB(host, port, user, password, dbname string) (*gorm.DB, error) {
 		&models.ProductDependency{},
 		&models.PromotionSession{},
 		&models.MarketingPromotion{},
+		&models.Zone{},
+		&models.Announcement{},
+		&models.HawkingProgram{},
+		&models.HawkingSessionRecord{},
+		&models.HawkingTaskRecord{},
+		&models.HubMessage{},
+		&models.StoreGrant{},
+		&models.PlayLog{},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("数据库迁移失败: " + err.Error())
+		return nil, fmt.Errorf("数据库迁移失败: %w", err)
 	}
 	fmt.Println("✅ 数据库初始化完成，表结构已就绪")
 	return db, nil
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"hawker-backend/middleware"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 广播记录列表默认返回的条数
+const announcementListLimit = 50
+
+// 创建和查询广播的门店权限由路由上的中间件校验，按 ID 停止广播时查出记录后校验
+type AnnouncementHandler struct {
+	Repo      repositories.AnnouncementRepository
+	ZoneRepo  repositories.ZoneRepository
+	Grants    repositories.StoreGrantRepository
+	Announcer *services.Announcer
+}
+
+func NewAnnouncementHandler(repo repositories.AnnouncementRepository, zoneRepo repositories.ZoneRepository, grants repositories.StoreGrantRepository, announcer *services.Announcer) *AnnouncementHandler {
+	return &AnnouncementHandler{Repo: repo, ZoneRepo: zoneRepo, Grants: grants, Announcer: announcer}
+}
+
+// CreateAnnouncement 插播一条临时广播（打烊、寻人、排队提示等）
+func (h *AnnouncementHandler) CreateAnnouncement(c *gin.Context) {
+	var req models.AnnouncementReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	storeID, err := uuid.Parse(req.StoreID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的门店ID"})
+		return
+	}
+	zoneID, zone, err := resolveSession(h.ZoneRepo, req.StoreID, req.ZoneID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	announcement := &models.Announcement{
+		StoreID:           storeID,
+		Text:              req.Text,
+		VoiceType:         req.VoiceType,
+		Repeat:            req.Repeat,
+		RepeatIntervalSec: req.RepeatIntervalSec,
+	}
+	if zone != nil {
+		announcement.ZoneID = zoneID
+	}
+
+	if err := h.Announcer.Announce(announcement); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "announcement": announcement})
+		return
+	}
+
+	c.JSON(http.StatusCreated, announcement)
+}
+
+// CancelAnnouncement 停止一条还在重复播放的广播
+func (h *AnnouncementHandler) CancelAnnouncement(c *gin.Context) {
+	announcement, err := h.Repo.FindByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "广播不存在或已播放结束"})
+		return
+	}
+	if !middleware.CheckStoreAccess(c, h.Grants, announcement.StoreID.String()) {
+		return
+	}
+
+	if !h.Announcer.Cancel(announcement.ID.String()) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "广播不存在或已播放结束"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "广播已停止"})
+}
+
+// GetAnnouncements 门店的广播记录，按时间倒序
+func (h *AnnouncementHandler) GetAnnouncements(c *gin.Context) {
+	list, err := h.Repo.FindByStoreID(c.Param("id"), announcementListLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询广播记录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
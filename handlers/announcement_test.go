@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type fakeAnnouncements struct {
+	repositories.AnnouncementRepository
+	records map[string]*models.Announcement
+}
+
+func (r *fakeAnnouncements) FindByID(id string) (*models.Announcement, error) {
+	if a, ok := r.records[id]; ok {
+		return a, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// newOwnerRouter 模拟 AuthMiddleware 写入当前店主
+func newOwnerRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("current_owner_id", uuid.New()) })
+	return r
+}
+
+func TestCancelAnnouncementChecksStore(t *testing.T) {
+	mine := &models.Announcement{Base: models.Base{ID: uuid.New()}, StoreID: uuid.New()}
+	other := &models.Announcement{Base: models.Base{ID: uuid.New()}, StoreID: uuid.New()}
+	h := NewAnnouncementHandler(
+		&fakeAnnouncements{records: map[string]*models.Announcement{mine.ID.String(): mine, other.ID.String(): other}},
+		nil,
+		&fakeGrants{access: map[string]bool{mine.StoreID.String(): true}},
+		services.NewAnnouncer(nil, nil, nil, nil),
+	)
+	r := newOwnerRouter()
+	r.DELETE("/hawking/announcements/:id", h.CancelAnnouncement)
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"自己门店的广播（已播完）", mine.ID.String(), http.StatusNotFound},
+		{"其他门店的广播", other.ID.String(), http.StatusForbidden},
+		{"广播不存在", uuid.NewString(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/hawking/announcements/"+tt.id, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 播放统计单次最多查询的天数
+const maxPlayStatsDays = 92
+
+type PlayLogHandler struct {
+	Repo   repositories.PlayLogRepository
+	Grants repositories.StoreGrantRepository
+}
+
+func NewPlayLogHandler(repo repositories.PlayLogRepository, grants repositories.StoreGrantRepository) *PlayLogHandler {
+	return &PlayLogHandler{Repo: repo, Grants: grants}
+}
+
+// recordPlay 记录音箱通过 WebSocket 上报的 PLAY_STARTED / PLAY_FINISHED / PLAY_FAILED
+func (h *PlayLogHandler) recordPlay(identity services.ClientIdentity, status string, req models.PlayReportReq) (gin.H, int, error) {
+	if req.SessionID == "" {
+		// 与 resolveSession 一致：不指定分区时是门店默认会话
+		req.SessionID = req.StoreID
+		if req.ZoneID != "" {
+			req.SessionID = req.ZoneID
+		}
+	}
+
+	playLog, err := req.ToPlayLog(status, time.Now())
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("参数错误: %v", err)
+	}
+	if status, err := h.checkSession(playLog.StoreID, req.ZoneID, req.SessionID); err != nil {
+		return nil, status, err
+	}
+	playLog.SessionID = services.SessionKey(req.SessionID)
+	if identity.DeviceID != uuid.Nil {
+		deviceID := identity.DeviceID
+		playLog.DeviceID = &deviceID
+	}
+
+	if err := h.Repo.Record(playLog); errors.Is(err, repositories.ErrPlayIDTaken) {
+		return nil, http.StatusConflict, err
+	} else if err != nil {
+		log.Printf("❌ 记录播放失败 [%s]: %v", req.PlayID, err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("记录播放失败")
+	}
+	if status == models.PlayFailed {
+		log.Printf("⚠️ 音箱播放失败 [%s] 商品 %s: %s", identity, req.ProductID, req.Error)
+	}
+	return gin.H{"play_id": req.PlayID, "status": status}, http.StatusOK, nil
+}
+
+// checkSession 上报的会话必须属于该门店；指定了分区时必须是这个分区的会话，否则是门店默认会话
+func (h *PlayLogHandler) checkSession(storeID uuid.UUID, zoneID string, sessionID string) (int, error) {
+	sessStoreID, sessZoneID, err := h.Grants.ResolveSession(sessionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusBadRequest, fmt.Errorf("会话不存在")
+	}
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("校验会话失败")
+	}
+	if sessStoreID != storeID {
+		return http.StatusForbidden, fmt.Errorf("会话不属于该门店")
+	}
+
+	if zoneID == "" {
+		if sessZoneID != nil {
+			return http.StatusBadRequest, fmt.Errorf("会话与分区不一致")
+		}
+		return http.StatusOK, nil
+	}
+	if sessZoneID == nil || services.SessionKey(zoneID) != sessZoneID.String() {
+		return http.StatusBadRequest, fmt.Errorf("会话与分区不一致")
+	}
+	return http.StatusOK, nil
+}
+
+// GetPlayStats 门店按天的播放统计：/stores/:id/plays/stats?from=2026-03-01&to=2026-03-07
+// 不传日期时统计今天；每个音箱的播放各算一次。门店权限由 StoreAccess 中间件校验
+func (h *PlayLogHandler) GetPlayStats(c *gin.Context) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的门店ID"})
+		return
+	}
+
+	today := time.Now().Format(models.PlayDateLayout)
+	from, errFrom := time.ParseInLocation(models.PlayDateLayout, c.DefaultQuery("from", today), time.Local)
+	to, errTo := time.ParseInLocation(models.PlayDateLayout, c.DefaultQuery("to", c.DefaultQuery("from", today)), time.Local)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "日期格式应为 2006-01-02"})
+		return
+	}
+	if to.Before(from) || to.Sub(from) >= maxPlayStatsDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("日期范围不正确，最多查询 %d 天", maxPlayStatsDays)})
+		return
+	}
+
+	stats, err := h.Repo.DailyStats(storeID.String(), from.Format(models.PlayDateLayout), to.Format(models.PlayDateLayout))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询播放统计失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"store_id": storeID,
+		"from":     from.Format(models.PlayDateLayout),
+		"to":       to.Format(models.PlayDateLayout),
+		"days":     models.GroupPlayStats(stats),
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type fakeGrants struct {
+	repositories.StoreGrantRepository
+	zones  map[uuid.UUID]uuid.UUID // 分区 -> 门店
+	stores map[uuid.UUID]bool
+	access map[string]bool // 当前店主能访问的门店
+}
+
+func (g *fakeGrants) CanAccessStore(ownerID uuid.UUID, storeID string) (bool, error) {
+	return g.access[storeID], nil
+}
+
+func (g *fakeGrants) ResolveSession(sessionID string) (uuid.UUID, *uuid.UUID, error) {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return uuid.Nil, nil, gorm.ErrRecordNotFound
+	}
+	if storeID, ok := g.zones[id]; ok {
+		return storeID, &id, nil
+	}
+	if g.stores[id] {
+		return id, nil, nil
+	}
+	return uuid.Nil, nil, gorm.ErrRecordNotFound
+}
+
+type fakePlayLogs struct {
+	repositories.PlayLogRepository
+	owners map[uuid.UUID]uuid.UUID // play_id -> 门店
+}
+
+func (r *fakePlayLogs) Record(log *models.PlayLog) error {
+	if storeID, ok := r.owners[log.ID]; ok && storeID != log.StoreID {
+		return repositories.ErrPlayIDTaken
+	}
+	r.owners[log.ID] = log.StoreID
+	return nil
+}
+
+func TestRecordPlayChecksSessionAndStore(t *testing.T) {
+	storeA, storeB := uuid.New(), uuid.New()
+	zoneA, zoneB := uuid.New(), uuid.New()
+	takenID := uuid.New()
+	h := NewPlayLogHandler(
+		&fakePlayLogs{owners: map[uuid.UUID]uuid.UUID{takenID: storeA}},
+		&fakeGrants{
+			zones:  map[uuid.UUID]uuid.UUID{zoneA: storeA, zoneB: storeB},
+			stores: map[uuid.UUID]bool{storeA: true, storeB: true},
+		},
+	)
+
+	tests := []struct {
+		name      string
+		playID    uuid.UUID
+		storeID   uuid.UUID
+		zoneID    string
+		sessionID string
+		want      int
+	}{
+		{"默认会话", uuid.New(), storeA, "", "", http.StatusOK},
+		{"分区会话", uuid.New(), storeA, zoneA.String(), "", http.StatusOK},
+		{"大写的分区会话", uuid.New(), storeA, strings.ToUpper(zoneA.String()), strings.ToUpper(zoneA.String()), http.StatusOK},
+		{"其他门店的分区", uuid.New(), storeA, "", zoneB.String(), http.StatusForbidden},
+		{"其他门店的默认会话", uuid.New(), storeA, "", storeB.String(), http.StatusForbidden},
+		{"会话与分区不一致", uuid.New(), storeA, zoneA.String(), storeA.String(), http.StatusBadRequest},
+		{"会话不存在", uuid.New(), storeA, "", uuid.NewString(), http.StatusBadRequest},
+		{"其他门店复用 play_id", takenID, storeB, "", "", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.PlayReportReq{
+				PlayID:    tt.playID.String(),
+				StoreID:   tt.storeID.String(),
+				ZoneID:    tt.zoneID,
+				SessionID: tt.sessionID,
+				ProductID: uuid.NewString(),
+			}
+			_, status, err := h.recordPlay(services.ClientIdentity{}, models.PlayStarted, req)
+			if status != tt.want {
+				t.Errorf("状态码 = %d (%v)，期望 %d", status, err, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"fmt"
+	"hawker-backend/logic"
 	"hawker-backend/models"
 	"hawker-backend/repositories"
 	"hawker-backend/services"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,13 +16,47 @@ import (
 )
 
 type ProductHandler struct {
-	Repo      repositories.ProductRepository
-	Scheduler *services.HawkingScheduler
+	Repo          repositories.ProductRepository
+	PromotionRepo repositories.PromotionRepository
+	ZoneRepo      repositories.ZoneRepository
+	Scheduler     *services.HawkingScheduler
+	StockRules    *services.StockRuleEngine // 为 nil 时不根据库存切换叫卖模式
 }
 
 // NewProductHandler 构造函数，强制注入 Repository
-func NewProductHandler(repo repositories.ProductRepository, Scheduler *services.HawkingScheduler) *ProductHandler {
-	return &ProductHandler{Repo: repo, Scheduler: Scheduler}
+func NewProductHandler(repo repositories.ProductRepository, promotionRepo repositories.PromotionRepository, zoneRepo repositories.ZoneRepository, Scheduler *services.HawkingScheduler, stockRules *services.StockRuleEngine) *ProductHandler {
+	return &ProductHandler{Repo: repo, PromotionRepo: promotionRepo, ZoneRepo: zoneRepo, Scheduler: Scheduler, StockRules: stockRules}
+}
+
+// resolveSession 根据门店和分区确定 SessionID
+func (h *ProductHandler) resolveSession(storeID string, zoneID string) (sessionID string, zone *models.Zone, err error) {
+	return resolveSession(h.ZoneRepo, storeID, zoneID)
+}
+
+// resolveSession 不指定分区时使用门店默认会话（SessionID 即 StoreID），否则每个分区一个独立的 Session
+func resolveSession(zoneRepo repositories.ZoneRepository, storeID string, zoneID string) (sessionID string, zone *models.Zone, err error) {
+	if zoneID == "" {
+		return storeID, nil, nil
+	}
+	zone, err = zoneRepo.FindByID(zoneID)
+	if err != nil {
+		return "", nil, fmt.Errorf("分区不存在")
+	}
+	if !strings.EqualFold(zone.StoreID.String(), storeID) {
+		return "", nil, fmt.Errorf("非法操作：分区与门店不匹配")
+	}
+	return zone.ID.String(), zone, nil
+}
+
+// respond 把 REST 与 WebSocket 命令共用的处理结果写回 HTTP 响应
+func respond(c *gin.Context) func(body gin.H, status int, err error) {
+	return func(body gin.H, status int, err error) {
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(status, body)
+	}
 }
 
 // CreateProduct 创建商品
@@ -99,9 +136,19 @@ func (h *ProductHandler) SyncProductsHandler(c *gin.Context) {
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "同步失败: " + err.Error()})
-	} else {
-		c.JSON(200, gin.H{"status": "ok", "message": fmt.Sprintf("同步成功，共处理 %d 条数据", len(items))})
+		return
+	}
+
+	// 库存已落库，按规则切换叫卖模式（正在叫卖的商品会重新生成文案）
+	modeChanged := 0
+	if h.StockRules != nil {
+		ids := make([]uuid.UUID, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		modeChanged = h.StockRules.OnProductsSynced(ids)
 	}
+	c.JSON(200, gin.H{"status": "ok", "message": fmt.Sprintf("同步成功，共处理 %d 条数据", len(items)), "mode_changed": modeChanged})
 }
 
 // 获取所有叫卖任务
@@ -111,34 +158,112 @@ func (h *ProductHandler) GetHawkingTasksHandler(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "必须提供 session_id 以定位叫卖任务"})
 		return
 	}
-	currentTasks := h.Scheduler.GetActiveTasksSnapshot(storeId)
+	respond(c)(h.tasksSnapshot(models.SessionStateReq{StoreID: storeId, ZoneID: c.Query("zone_id")}))
+}
 
-	c.JSON(200, gin.H{
+func (h *ProductHandler) tasksSnapshot(req models.SessionStateReq) (gin.H, int, error) {
+	sessionID, _, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		return nil, 403, err
+	}
+	// 服务重启后 Session 会从数据库恢复，这里只有真正存在会话时才返回 resumed
+	if !h.Scheduler.HasSession(sessionID) {
+		return gin.H{
+			"status":  "empty",
+			"message": "当前没有进行中的叫卖会话",
+			"tasks":   h.Scheduler.GetActiveTasksSnapshot(sessionID),
+		}, 200, nil
+	}
+
+	currentTasks := h.Scheduler.GetActiveTasksSnapshot(sessionID)
+
+	return gin.H{
 		"status":  "resumed",
 		"message": "已恢复叫卖会话",
 		"tasks":   currentTasks,
-	})
+	}, 200, nil
 }
 
-// AddHawkingTaskHandler 添加叫卖任务
-func (h *ProductHandler) AddHawkingTaskHandler(c *gin.Context) {
-	var req models.AddTaskReq
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+// GetTaskChangesHandler 增量同步：返回 since 修订号之后的变化
+// 参数 epoch、since 取自上一次快照或增量结果，intro_version 为客户端持有的开场白池版本
+func (h *ProductHandler) GetTaskChangesHandler(c *gin.Context) {
+	storeID := c.Query("store_id")
+	if storeID == "" {
+		c.JSON(400, gin.H{"error": "必须提供 store_id 以定位叫卖任务"})
+		return
+	}
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		c.JSON(400, gin.H{"error": "since 参数错误"})
+		return
+	}
+
+	sessionID, _, err := h.resolveSession(storeID, c.Query("zone_id"))
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
 		return
 	}
 
+	c.JSON(200, h.Scheduler.GetTasksDelta(sessionID, c.Query("epoch"), since, c.Query("intro_version")))
+}
+
+// validateTaskReq 校验添加任务的参数，并确保商品属于该门店；失败时返回对应的 HTTP 状态码
+func (h *ProductHandler) validateTaskReq(req *models.AddTaskReq) (*models.Product, int, error) {
+	if req.TaskID != "" {
+		if _, err := uuid.Parse(req.TaskID); err != nil {
+			return nil, 400, fmt.Errorf("参数错误: task_id 格式不正确")
+		}
+	}
+	// 校验生效时间设置
+	if err := req.TaskWindow.Validate(time.Now()); err != nil {
+		return nil, 400, fmt.Errorf("参数错误: %v", err)
+	}
+	if err := models.ValidateMarkdowns(req.Markdowns); err != nil {
+		return nil, 400, fmt.Errorf("参数错误: %v", err)
+	}
+	// 自定义文案里的价格是写死的，无法随降价自动改写
+	if len(req.Markdowns) > 0 && req.Text != "" {
+		return nil, 400, fmt.Errorf("自定义文案的任务不支持自动降价")
+	}
+
 	// 安全校验：确保商品属于该门店
 	product, err := h.Repo.FindByID(req.ProductID)
 	storeId, _ := uuid.Parse(req.StoreID)
 	if err != nil || product.StoreID != storeId {
-		c.JSON(403, gin.H{"error": "非法操作：商品与门店不匹配"})
+		return nil, 403, fmt.Errorf("非法操作：商品与门店不匹配")
+	}
+	return product, 200, nil
+}
+
+// AddHawkingTaskHandler 添加叫卖任务
+func (h *ProductHandler) AddHawkingTaskHandler(c *gin.Context) {
+	var req models.AddTaskReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
 		return
 	}
 
-	// 策略：将 StoreID 作为 SessionID
-	// 这样能保证每个门店只有一个独立的 runSessionLoop 在运行
-	sessionID := req.StoreID
+	respond(c)(h.addTask(req))
+}
+
+func (h *ProductHandler) addTask(req models.AddTaskReq) (gin.H, int, error) {
+	product, status, err := h.validateTaskReq(&req)
+	if err != nil {
+		return nil, status, err
+	}
+
+	// 策略：将 StoreID 作为门店默认会话的 SessionID，指定分区时使用 ZoneID
+	// 这样能保证每个门店（分区）只有一个独立的 runSessionLoop 在运行
+	sessionID, zone, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		return nil, 403, err
+	}
+	if zone != nil {
+		req.ZoneID = sessionID
+		if req.VoiceType == "" {
+			req.VoiceType = zone.VoiceType
+		}
+	}
 	// 1. 调用 Scheduler 的 AddTask (内部会自动处理 Session 的懒加载启动)
 	h.Scheduler.AddTask(product, req, sessionID)
 
@@ -146,16 +271,246 @@ func (h *ProductHandler) AddHawkingTaskHandler(c *gin.Context) {
 	currentTasks := h.Scheduler.GetActiveTasksSnapshot(sessionID)
 
 	// 3. 返回结果给 Swift 端
-	c.JSON(200, gin.H{
+	return gin.H{
 		"message":    "任务已同步",
 		"session_id": sessionID,
 		"tasks":      currentTasks,
+	}, 200, nil
+}
+
+// BatchAddTasksHandler 批量添加叫卖任务，全部校验通过才会生效
+func (h *ProductHandler) BatchAddTasksHandler(c *gin.Context) {
+	h.applyTaskBatch(c, false)
+}
+
+// ReplaceTasksHandler 用请求中的任务整体替换会话中的所有任务
+func (h *ProductHandler) ReplaceTasksHandler(c *gin.Context) {
+	h.applyTaskBatch(c, true)
+}
+
+func (h *ProductHandler) applyTaskBatch(c *gin.Context, replaceAll bool) {
+	var req models.BatchTaskReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if !replaceAll && len(req.Tasks) == 0 {
+		c.JSON(400, gin.H{"error": "tasks 不能为空"})
+		return
+	}
+
+	sessionID, zone, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	op := services.BatchOp{StoreID: req.StoreID, VoiceType: req.VoiceType, ReplaceAll: replaceAll}
+	if zone != nil {
+		op.ZoneID = sessionID
+		if op.VoiceType == "" {
+			op.VoiceType = zone.VoiceType
+		}
+	}
+
+	// 任意一项不合法，整批都不生效
+	for i := range req.Tasks {
+		item := req.Tasks[i]
+		item.StoreID = req.StoreID
+		item.ZoneID = op.ZoneID
+		if item.VoiceType == "" {
+			item.VoiceType = op.VoiceType
+		}
+		product, status, err := h.validateTaskReq(&item)
+		if err != nil {
+			c.JSON(status, gin.H{"error": fmt.Sprintf("第 %d 个任务: %v", i+1, err), "product_id": item.ProductID})
+			return
+		}
+		op.Add = append(op.Add, services.TaskSpec{Product: product, Req: item})
+	}
+
+	c.JSON(200, gin.H{
+		"message":    fmt.Sprintf("已同步 %d 个任务", len(op.Add)),
+		"session_id": sessionID,
+		"tasks":      h.Scheduler.ApplyBatch(sessionID, op),
+	})
+}
+
+// BatchRemoveTasksHandler 批量移除叫卖任务
+func (h *ProductHandler) BatchRemoveTasksHandler(c *gin.Context) {
+	var req models.BatchRemoveReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	respond(c)(h.removeTasks(req))
+}
+
+func (h *ProductHandler) removeTasks(req models.BatchRemoveReq) (gin.H, int, error) {
+	if len(req.TaskIDs) == 0 && len(req.ProductIDs) == 0 {
+		return nil, 400, fmt.Errorf("task_ids 和 product_ids 不能都为空")
+	}
+
+	sessionID, _, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		return nil, 403, err
+	}
+
+	// 任务 ID 和商品 ID 都交给调度器匹配：商品 ID 会移除该商品的所有任务
+	refs := append(append([]string{}, req.TaskIDs...), req.ProductIDs...)
+	return gin.H{
+		"message":    "移除成功",
+		"session_id": sessionID,
+		"tasks":      h.Scheduler.ApplyBatch(sessionID, services.BatchOp{Remove: refs}),
+	}, 200, nil
+}
+
+// ReorderTasksHandler 按客户端拖动后的顺序调整任务排列
+func (h *ProductHandler) ReorderTasksHandler(c *gin.Context) {
+	var req models.ReorderTasksReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	sessionID, _, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot := h.Scheduler.ReorderTasks(sessionID, req.TaskIDs)
+	if snapshot == nil {
+		c.JSON(404, gin.H{"error": "当前没有进行中的叫卖会话"})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message":    "顺序已更新",
+		"session_id": sessionID,
+		"tasks":      snapshot,
+	})
+}
+
+// ClearTasksHandler 清空会话中的所有任务（会话随之销毁）
+func (h *ProductHandler) ClearTasksHandler(c *gin.Context) {
+	storeID := c.Query("store_id")
+	if storeID == "" {
+		c.JSON(400, gin.H{"error": "必须提供 store_id 以定位叫卖任务"})
+		return
+	}
+
+	sessionID, _, err := h.resolveSession(storeID, c.Query("zone_id"))
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":    "已清空",
+		"session_id": sessionID,
+		"tasks":      h.Scheduler.ApplyBatch(sessionID, services.BatchOp{ReplaceAll: true}),
+	})
+}
+
+// PauseSessionHandler 暂停叫卖：任务和已合成的音频都保留，音箱停止播放
+func (h *ProductHandler) PauseSessionHandler(c *gin.Context) {
+	h.setSessionState(c, services.SessionPaused)
+}
+
+// ResumeSessionHandler 恢复暂停的叫卖
+func (h *ProductHandler) ResumeSessionHandler(c *gin.Context) {
+	h.setSessionState(c, services.SessionPlaying)
+}
+
+// StopSessionHandler 停止叫卖：移除所有任务并销毁会话
+func (h *ProductHandler) StopSessionHandler(c *gin.Context) {
+	h.setSessionState(c, services.SessionStopped)
+}
+
+func (h *ProductHandler) setSessionState(c *gin.Context, state string) {
+	var req models.SessionStateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	respond(c)(h.changeSessionState(req, state))
+}
+
+func (h *ProductHandler) changeSessionState(req models.SessionStateReq, state string) (gin.H, int, error) {
+	sessionID, _, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		return nil, 403, err
+	}
+
+	switch state {
+	case services.SessionStopped:
+		return gin.H{
+			"state":      state,
+			"session_id": sessionID,
+			"tasks":      h.Scheduler.StopSession(sessionID),
+		}, 200, nil
+	case services.SessionPaused:
+		err = h.Scheduler.PauseSession(sessionID)
+	default:
+		err = h.Scheduler.ResumeSession(sessionID)
+	}
+	if err != nil {
+		return nil, 404, err
+	}
+
+	return gin.H{
+		"state":      state,
+		"session_id": sessionID,
+		"tasks":      h.Scheduler.GetActiveTasksSnapshot(sessionID),
+	}, 200, nil
+}
+
+// PreviewScriptsHandler 生成多条候选文案供店主挑选，不调用 TTS
+// 第一条是复读机模式的固定文案，只有这一条；其余是智能描述模式，mode 字段标明来源
+// 选中的文案作为 text 传给添加任务接口即可
+func (h *ProductHandler) PreviewScriptsHandler(c *gin.Context) {
+	var req models.ScriptPreviewReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if req.Count <= 0 {
+		req.Count = 5
+	}
+	if req.Count > 10 {
+		req.Count = 10
+	}
+
+	// 安全校验：确保商品属于该门店
+	product, err := h.Repo.FindByID(req.ProductID)
+	storeId, _ := uuid.Parse(req.StoreID)
+	if err != nil || product.StoreID != storeId {
+		c.JSON(403, gin.H{"error": "非法操作：商品与门店不匹配"})
+		return
+	}
+
+	// 与 AddTask 一致：已经过了降价时间的，按当前价格生成
+	price, originalPrice := models.MarkdownPrices(req.Price, req.OriginalPrice, models.MarkdownRatioAt(req.Markdowns, time.Now()))
+	task := &models.HawkingTask{
+		ProductID:     req.ProductID,
+		Price:         price,
+		OriginalPrice: originalPrice,
+		Unit:          req.Unit,
+		MinQty:        req.MinQty,
+		ConditionUnit: req.ConditionUnit,
+		PromotionTag:  req.PromotionTag,
+		UseRepeatMode: req.UseRepeatMode,
+	}
+
+	c.JSON(200, gin.H{
+		"product_id": req.ProductID,
+		"candidates": logic.GenerateCandidates(*product, task, req.Count),
 	})
 }
 
-// RemoveHawkingTaskHandler 移除叫卖任务
+// RemoveHawkingTaskHandler 移除叫卖任务：路径参数为任务 ID；旧版客户端传商品 ID 时移除该商品的所有任务
 func (h *ProductHandler) RemoveHawkingTaskHandler(c *gin.Context) {
-	productID := c.Param("id")
+	taskID := c.Param("id")
 	storeID := c.Query("store_id") // 对应 Swift: .../tasks/123?store_id=ABC
 
 	if storeID == "" {
@@ -163,9 +518,13 @@ func (h *ProductHandler) RemoveHawkingTaskHandler(c *gin.Context) {
 		return
 	}
 
-	sessionID := storeID
+	sessionID, _, err := h.resolveSession(storeID, c.Query("zone_id"))
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
 	// 1. 从 Session 中移除任务 (如果任务清空，Scheduler 会自动 StopSession)
-	h.Scheduler.RemoveTask(sessionID, productID)
+	h.Scheduler.RemoveTask(sessionID, taskID)
 
 	// 2. 获取快照
 	// 注意：如果 Session 刚被销毁，这个方法会返回一个空的 Task 列表
@@ -178,6 +537,86 @@ func (h *ProductHandler) RemoveHawkingTaskHandler(c *gin.Context) {
 	})
 }
 
+// SetTaskVoiceHandler 为单个叫卖任务固定音色，或取消固定回到会话默认音色
+// 路径参数为任务 ID，传商品 ID 时作用于该商品的所有任务
+func (h *ProductHandler) SetTaskVoiceHandler(c *gin.Context) {
+	taskID := c.Param("id")
+	var req models.SetTaskVoiceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if req.Pinned && req.VoiceType == "" {
+		c.JSON(400, gin.H{"error": "固定音色时必须提供 voice_type"})
+		return
+	}
+
+	sessionID, _, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Scheduler.SetTaskVoice(sessionID, taskID, req.VoiceType, req.Pinned); err != nil {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"status":     "processing",
+		"session_id": sessionID,
+		"tasks":      h.Scheduler.GetActiveTasksSnapshot(sessionID),
+	})
+}
+
+// LoadPromotionHandler 将促销场次一键导入为叫卖任务
+func (h *ProductHandler) LoadPromotionHandler(c *gin.Context) {
+	promotionID := c.Param("id")
+	var req models.LoadPromotionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	promo, err := h.PromotionRepo.FindByID(promotionID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "促销活动不存在"})
+		return
+	}
+
+	// 安全校验：确保促销属于该门店
+	storeId, _ := uuid.Parse(req.StoreID)
+	if promo.StoreID != storeId {
+		c.JSON(403, gin.H{"error": "非法操作：促销与门店不匹配"})
+		return
+	}
+
+	if promo.Window().Expired(time.Now()) {
+		c.JSON(400, gin.H{"error": "促销活动已结束"})
+		return
+	}
+
+	sessionID, zone, err := h.resolveSession(req.StoreID, req.ZoneID)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	if zone != nil {
+		req.ZoneID = sessionID
+		if req.VoiceType == "" {
+			req.VoiceType = zone.VoiceType
+		}
+	}
+	loaded, skipped := h.Scheduler.LoadPromotion(promo, req, sessionID)
+
+	c.JSON(200, gin.H{
+		"message":    fmt.Sprintf("已导入 %d 个促销商品", loaded),
+		"session_id": sessionID,
+		"skipped":    skipped,
+		"tasks":      h.Scheduler.GetActiveTasksSnapshot(sessionID),
+	})
+}
+
 // 同步开场白
 func (h *ProductHandler) SyncIntroHandler(c *gin.Context) {
 	var req models.SyncIntroReq
@@ -190,28 +629,32 @@ func (h *ProductHandler) SyncIntroHandler(c *gin.Context) {
 
 // 切换音色
 func (h *ProductHandler) SwitchVoiceHandler(c *gin.Context) {
-	var req struct {
-		StoreId    string   `json:"store_id"`
-		VoiceID    string   `json:"voice_id"`
-		ProductIDs []string `json:"product_ids"`
-	}
+	var req models.SwitchVoiceReq
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"status": "参数错误", "session_id": req.StoreId})
 		return
 	}
+	respond(c)(h.switchVoice(req))
+}
+
+func (h *ProductHandler) switchVoice(req models.SwitchVoiceReq) (gin.H, int, error) {
+	sessionID, _, err := h.resolveSession(req.StoreId, req.ZoneID)
+	if err != nil {
+		return nil, 403, err
+	}
 
 	// 触发后端重置与重新合成任务
-	h.Scheduler.ChangeSessionVoice(req.StoreId, req.VoiceID, req.ProductIDs)
+	h.Scheduler.ChangeSessionVoice(sessionID, req.VoiceID)
 
-	currentTasks := h.Scheduler.GetActiveTasksSnapshot(req.StoreId)
+	currentTasks := h.Scheduler.GetActiveTasksSnapshot(sessionID)
 
 	// 3. 在接口响应中立即下发，让客户端知道“文案已经变了”
-	c.JSON(200, gin.H{
+	return gin.H{
 		"status":     "processing",
-		"session_id": req.StoreId,
+		"session_id": sessionID,
 		"tasks":      currentTasks,
-	})
+	}, 200, nil
 }
 
 // 同步依赖接口
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type fakeProducts struct {
+	repositories.ProductRepository
+	products map[string]*models.Product
+}
+
+func (r *fakeProducts) FindByID(id string) (*models.Product, error) {
+	if p, ok := r.products[id]; ok {
+		copied := *p
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeProducts) UpdateHawkingFields(id string, fields map[string]interface{}) error {
+	return nil
+}
+
+func (r *fakeProducts) UpdateHawkingStatus(id string, updates map[string]interface{}) error {
+	return nil
+}
+
+type fakeSessions struct {
+	repositories.HawkingSessionRepository
+}
+
+func (r *fakeSessions) SaveSession(record *models.HawkingSessionRecord) error { return nil }
+
+func (r *fakeSessions) DeleteSession(sessionID string) error { return nil }
+
+type fakeAudio struct{}
+
+func (fakeAudio) GenerateAudio(ctx context.Context, text string, identifier string, voiceType string) (string, error) {
+	return "/static/audio/" + identifier + ".mp3", nil
+}
+
+func (fakeAudio) GetRealVoiceID(voiceType string) string { return voiceType }
+
+func newTestScheduler(products *fakeProducts) *services.HawkingScheduler {
+	hub := services.NewHub()
+	go hub.Run()
+	executor := services.NewSynthesisExecutor(1, services.RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Second, Timeout: time.Second})
+	executor.Start()
+	return services.NewHawkingScheduler(products, repositories.NewMemIntroRepository(), &fakeSessions{}, fakeAudio{}, executor, hub)
+}
+
+func TestReplaceTasksRejectsWholeBatch(t *testing.T) {
+	storeID := uuid.New()
+	pork := &models.Product{Base: models.Base{ID: uuid.New()}, StoreID: storeID, Name: "五花肉", Unit: "斤"}
+	eggs := &models.Product{Base: models.Base{ID: uuid.New()}, StoreID: storeID, Name: "土鸡蛋", Unit: "斤"}
+	foreign := &models.Product{Base: models.Base{ID: uuid.New()}, StoreID: uuid.New(), Name: "草鱼", Unit: "斤"}
+	products := &fakeProducts{products: map[string]*models.Product{
+		pork.ID.String(): pork, eggs.ID.String(): eggs, foreign.ID.String(): foreign,
+	}}
+	h := NewProductHandler(products, nil, nil, newTestScheduler(products), nil)
+	r := newOwnerRouter()
+	r.POST("/hawking/tasks/batch", h.BatchAddTasksHandler)
+	r.PUT("/hawking/tasks", h.ReplaceTasksHandler)
+
+	send := func(method string, path string, productIDs ...uuid.UUID) *httptest.ResponseRecorder {
+		req := models.BatchTaskReq{StoreID: storeID.String(), VoiceType: models.VoiceSunnyBoy}
+		for _, id := range productIDs {
+			req.Tasks = append(req.Tasks, models.AddTaskReq{ProductID: id.String(), Text: "特价", Price: 10})
+		}
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(body)))
+		return w
+	}
+
+	if w := send(http.MethodPost, "/hawking/tasks/batch", pork.ID); w.Code != http.StatusOK {
+		t.Fatalf("批量添加: status = %d (%s)", w.Code, w.Body.String())
+	}
+	before := h.Scheduler.GetActiveTasksSnapshot(storeID.String())
+
+	// 第二项是其他门店的商品：整批拒绝，原有任务不能被清掉
+	if w := send(http.MethodPut, "/hawking/tasks", eggs.ID, foreign.ID); w.Code != http.StatusForbidden {
+		t.Fatalf("整体替换: status = %d, want %d (%s)", w.Code, http.StatusForbidden, w.Body.String())
+	}
+	after := h.Scheduler.GetActiveTasksSnapshot(storeID.String())
+	if len(after.Products) != 1 || after.Products[0].ID != before.Products[0].ID || after.Epoch != before.Epoch {
+		t.Errorf("整批被拒绝后任务有变化: before=%+v after=%+v", before.Products, after.Products)
+	}
+}
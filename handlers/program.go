@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"hawker-backend/middleware"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 新建和查询节目的门店权限由路由上的中间件校验，按 ID 修改、删除时查出节目后校验
+type ProgramHandler struct {
+	Repo     repositories.ProgramRepository
+	ZoneRepo repositories.ZoneRepository
+	Grants   repositories.StoreGrantRepository
+	Runner   *services.ProgramRunner
+}
+
+func NewProgramHandler(repo repositories.ProgramRepository, zoneRepo repositories.ZoneRepository, grants repositories.StoreGrantRepository, runner *services.ProgramRunner) *ProgramHandler {
+	return &ProgramHandler{Repo: repo, ZoneRepo: zoneRepo, Grants: grants, Runner: runner}
+}
+
+// applyProgramReq 将请求写入节目并校验，分区必须属于该门店
+func (h *ProgramHandler) applyProgramReq(program *models.HawkingProgram, req models.ProgramReq) error {
+	_, zone, err := resolveSession(h.ZoneRepo, program.StoreID.String(), req.ZoneID)
+	if err != nil {
+		return err
+	}
+	// 按数据库里的分区 ID 保存，不保留客户端传来的大小写
+	program.ZoneID = ""
+	if zone != nil {
+		program.ZoneID = zone.ID.String()
+	}
+	program.Name = req.Name
+	program.VoiceType = req.VoiceType
+	program.UseRepeatMode = req.UseRepeatMode
+	program.Enabled = req.Enabled == nil || *req.Enabled
+	program.Weekdays = req.Weekdays
+	program.DailyStart = req.DailyStart
+	program.DailyEnd = req.DailyEnd
+	program.Items = req.Items
+	return program.Validate()
+}
+
+// CreateProgram 新建叫卖节目，到点后自动加载
+func (h *ProgramHandler) CreateProgram(c *gin.Context) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的门店ID"})
+		return
+	}
+
+	var req models.ProgramReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	program := &models.HawkingProgram{StoreID: storeID}
+	if err := h.applyProgramReq(program, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := h.Repo.Create(program); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建节目失败"})
+		return
+	}
+
+	// 正好处于节目时段内的，立即加载
+	h.Runner.Reload(program)
+	c.JSON(http.StatusCreated, program)
+}
+
+// GetPrograms 门店的所有叫卖节目
+func (h *ProgramHandler) GetPrograms(c *gin.Context) {
+	programs, err := h.Repo.FindByStoreID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询节目失败"})
+		return
+	}
+	c.JSON(http.StatusOK, programs)
+}
+
+// UpdateProgram 修改节目，已加载的任务会按新配置重新加载
+func (h *ProgramHandler) UpdateProgram(c *gin.Context) {
+	program, err := h.Repo.FindByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "节目不存在"})
+		return
+	}
+	if !middleware.CheckStoreAccess(c, h.Grants, program.StoreID.String()) {
+		return
+	}
+
+	var req models.ProgramReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := h.applyProgramReq(program, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := h.Repo.Update(program); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改节目失败"})
+		return
+	}
+
+	h.Runner.Reload(program)
+	c.JSON(http.StatusOK, program)
+}
+
+// DeleteProgram 删除节目，并立即卸载它加载的任务
+func (h *ProgramHandler) DeleteProgram(c *gin.Context) {
+	program, err := h.Repo.FindByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "节目不存在"})
+		return
+	}
+	if !middleware.CheckStoreAccess(c, h.Grants, program.StoreID.String()) {
+		return
+	}
+
+	programID := program.ID.String()
+	if err := h.Repo.Delete(programID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除节目失败"})
+		return
+	}
+
+	h.Runner.Unload(programID)
+	c.JSON(http.StatusOK, gin.H{"message": "节目已删除"})
+}
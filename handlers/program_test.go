@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type fakePrograms struct {
+	repositories.ProgramRepository
+	records map[string]*models.HawkingProgram
+	writes  int
+}
+
+func (r *fakePrograms) FindByID(id string) (*models.HawkingProgram, error) {
+	if p, ok := r.records[id]; ok {
+		copied := *p
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakePrograms) Update(p *models.HawkingProgram) error {
+	r.writes++
+	return nil
+}
+
+func (r *fakePrograms) Delete(id string) error {
+	r.writes++
+	return nil
+}
+
+func TestProgramWritesCheckStore(t *testing.T) {
+	program := &models.HawkingProgram{Base: models.Base{ID: uuid.New()}, StoreID: uuid.New(), Name: "早市"}
+	repo := &fakePrograms{records: map[string]*models.HawkingProgram{program.ID.String(): program}}
+	// 当前店主无权访问节目所在的门店
+	h := NewProgramHandler(repo, nil, &fakeGrants{access: map[string]bool{}}, nil)
+	r := newOwnerRouter()
+	r.PUT("/hawking/programs/:id", h.UpdateProgram)
+	r.DELETE("/hawking/programs/:id", h.DeleteProgram)
+
+	body := `{"name":"改名","daily_start":"06:00","daily_end":"09:00"}`
+	tests := []struct {
+		name   string
+		method string
+		id     string
+		want   int
+	}{
+		{"修改其他门店的节目", http.MethodPut, program.ID.String(), http.StatusForbidden},
+		{"删除其他门店的节目", http.MethodDelete, program.ID.String(), http.StatusForbidden},
+		{"节目不存在", http.MethodDelete, uuid.NewString(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, "/hawking/programs/"+tt.id, strings.NewReader(body)))
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+	if repo.writes != 0 {
+		t.Errorf("无权访问时不能写入节目，实际写入 %d 次", repo.writes)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type StoreGrantHandler struct {
+	Repo repositories.StoreGrantRepository
+}
+
+func NewStoreGrantHandler(repo repositories.StoreGrantRepository) *StoreGrantHandler {
+	return &StoreGrantHandler{Repo: repo}
+}
+
+// requireOwner 门店授权只有店主本人可以管理，店员不能再授权给别人
+func (h *StoreGrantHandler) requireOwner(c *gin.Context) (uuid.UUID, bool) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的门店ID"})
+		return uuid.Nil, false
+	}
+
+	ownerID := c.MustGet("current_owner_id").(uuid.UUID)
+	isOwner, err := h.Repo.IsStoreOwner(ownerID, storeID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "校验门店权限失败"})
+		return uuid.Nil, false
+	}
+	if !isOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有店主可以管理门店授权"})
+		return uuid.Nil, false
+	}
+	return storeID, true
+}
+
+// GetGrants 门店的店员和设备授权列表
+func (h *StoreGrantHandler) GetGrants(c *gin.Context) {
+	storeID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+
+	grants, err := h.Repo.FindByStoreID(storeID.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询授权失败"})
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// CreateGrant 授权店员账号，或者为音箱生成设备令牌（令牌只在这里返回一次）
+func (h *StoreGrantHandler) CreateGrant(c *gin.Context) {
+	storeID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateGrantReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	resp := models.CreateGrantResp{StoreGrant: models.StoreGrant{StoreID: storeID, Kind: req.Kind, Name: req.Name}}
+	switch req.Kind {
+	case models.GrantStaff:
+		if req.Username == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请填写店员的登录名"})
+			return
+		}
+		staffID, err := h.Repo.FindOwnerIDByUsername(req.Username)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "账号不存在"})
+			return
+		}
+		if staffID == c.MustGet("current_owner_id").(uuid.UUID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能授权给自己"})
+			return
+		}
+		resp.OwnerID = &staffID
+		if resp.Name == "" {
+			resp.Name = req.Username
+		}
+
+	case models.GrantDevice:
+		if req.ZoneID != "" {
+			zoneID, err := uuid.Parse(req.ZoneID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分区ID"})
+				return
+			}
+			zoneStore, zone, err := h.Repo.ResolveSession(zoneID.String())
+			if err != nil || zone == nil || zoneStore != storeID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "分区不属于该门店"})
+				return
+			}
+			resp.ZoneID = zone
+		}
+		token, hash, err := utils.GenerateDeviceToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成设备令牌失败"})
+			return
+		}
+		resp.Token = token
+		resp.TokenHash = hash
+	}
+
+	if err := h.Repo.Create(&resp.StoreGrant); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建授权失败"})
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RevokeGrant 吊销授权，之后该店员或设备无法再建立连接
+func (h *StoreGrantHandler) RevokeGrant(c *gin.Context) {
+	storeID, ok := h.requireOwner(c)
+	if !ok {
+		return
+	}
+	if _, err := uuid.Parse(c.Param("grant_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的授权ID"})
+		return
+	}
+
+	err := h.Repo.Delete(storeID.String(), c.Param("grant_id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "授权不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销授权失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// WsCommandHandler 处理客户端通过 WebSocket 发来的命令
+// 命令与 REST 接口共用 ProductHandler 的处理逻辑，校验、状态码和响应体都保持一致
+type WsCommandHandler struct {
+	Products *ProductHandler
+	Plays    *PlayLogHandler
+	Grants   repositories.StoreGrantRepository
+}
+
+func NewWsCommandHandler(products *ProductHandler, plays *PlayLogHandler, grants repositories.StoreGrantRepository) *WsCommandHandler {
+	return &WsCommandHandler{Products: products, Plays: plays, Grants: grants}
+}
+
+// 播放回执对应的播放记录状态
+var playStatuses = map[string]string{
+	models.CmdPlayStarted:  models.PlayStarted,
+	models.CmdPlayFinished: models.PlayFinished,
+	models.CmdPlayFailed:   models.PlayFailed,
+}
+
+// commandScope 所有命令的 data 都带 store_id / zone_id，执行前先校验连接能否操作该门店（分区）
+type commandScope struct {
+	StoreID string `json:"store_id"`
+	ZoneID  string `json:"zone_id"`
+}
+
+func (h *WsCommandHandler) HandleCommand(client *services.Client, raw []byte) models.WSMessage {
+	var cmd models.WSCommand
+	if err := json.Unmarshal(raw, &cmd); err != nil {
+		return commandError(cmd, http.StatusBadRequest, fmt.Errorf("无法解析的命令: %v", err))
+	}
+
+	result, status, err := h.dispatch(client.Identity, cmd)
+	if err != nil {
+		log.Printf("⚠️ WebSocket 命令失败 [%s] %s: %v", client.Identity, cmd.Action, err)
+		return commandError(cmd, status, err)
+	}
+	return models.WSMessage{
+		Type: "ACK",
+		Data: models.CommandAckData{ID: cmd.ID, Action: cmd.Action, Result: result},
+	}
+}
+
+func (h *WsCommandHandler) dispatch(identity services.ClientIdentity, cmd models.WSCommand) (gin.H, int, error) {
+	switch cmd.Action {
+	case models.CmdAddTask, models.CmdRemoveTasks, models.CmdSwitchVoice,
+		models.CmdPause, models.CmdResume, models.CmdStop, models.CmdGetSnapshot,
+		models.CmdPlayStarted, models.CmdPlayFinished, models.CmdPlayFailed:
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("未知的命令: %s", cmd.Action)
+	}
+	if status, err := h.authorize(identity, cmd.Data); err != nil {
+		return nil, status, err
+	}
+
+	switch cmd.Action {
+	case models.CmdAddTask:
+		var req models.AddTaskReq
+		if err := bindCommand(cmd.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return h.Products.addTask(req)
+	case models.CmdRemoveTasks:
+		var req models.BatchRemoveReq
+		if err := bindCommand(cmd.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return h.Products.removeTasks(req)
+	case models.CmdSwitchVoice:
+		var req models.SwitchVoiceReq
+		if err := bindCommand(cmd.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return h.Products.switchVoice(req)
+	case models.CmdPlayStarted, models.CmdPlayFinished, models.CmdPlayFailed:
+		var req models.PlayReportReq
+		if err := bindCommand(cmd.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return h.Plays.recordPlay(identity, playStatuses[cmd.Action], req)
+	}
+
+	var req models.SessionStateReq
+	if err := bindCommand(cmd.Data, &req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	switch cmd.Action {
+	case models.CmdPause:
+		return h.Products.changeSessionState(req, services.SessionPaused)
+	case models.CmdResume:
+		return h.Products.changeSessionState(req, services.SessionPlaying)
+	case models.CmdStop:
+		return h.Products.changeSessionState(req, services.SessionStopped)
+	default:
+		return h.Products.tasksSnapshot(req)
+	}
+}
+
+// authorize 与订阅使用同一套规则：店主或店员按门店校验，设备只能操作所属门店（分区）
+// 分区是否属于该门店由 resolveSession 在执行时校验
+func (h *WsCommandHandler) authorize(identity services.ClientIdentity, data json.RawMessage) (int, error) {
+	var scope commandScope
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &scope); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("参数错误: %v", err)
+		}
+	}
+	storeID, err := uuid.Parse(scope.StoreID)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("无效的门店ID")
+	}
+
+	var zoneID *uuid.UUID
+	if scope.ZoneID != "" {
+		id, err := uuid.Parse(scope.ZoneID)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("无效的分区ID")
+		}
+		zoneID = &id
+	}
+	return checkAccess(h.Grants, identity, storeID, zoneID)
+}
+
+// bindCommand 解析并校验命令参数，校验规则与 ShouldBindJSON 相同
+func bindCommand(data json.RawMessage, obj interface{}) error {
+	if err := binding.JSON.BindBody(data, obj); err != nil {
+		return fmt.Errorf("参数错误: %v", err)
+	}
+	return nil
+}
+
+func commandError(cmd models.WSCommand, status int, err error) models.WSMessage {
+	return models.WSMessage{
+		Type: "ERROR",
+		Data: models.CommandErrorData{ID: cmd.ID, Action: cmd.Action, Code: status, Error: err.Error()},
+	}
+}
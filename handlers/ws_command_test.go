@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"hawker-backend/models"
+	"hawker-backend/services"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestWsCommands(t *testing.T) {
+	storeID, otherStore := uuid.New(), uuid.New()
+	pork := &models.Product{Base: models.Base{ID: uuid.New()}, StoreID: storeID, Name: "五花肉", Unit: "斤"}
+	products := &fakeProducts{products: map[string]*models.Product{pork.ID.String(): pork}}
+	h := NewWsCommandHandler(NewProductHandler(products, nil, nil, newTestScheduler(products), nil), nil, &fakeGrants{})
+	speaker := services.NewClient(nil, nil, nil, services.ClientIdentity{DeviceID: uuid.New(), StoreID: storeID})
+
+	send := func(id string, action string, data gin.H) models.WSMessage {
+		raw, _ := json.Marshal(gin.H{"id": id, "action": action, "data": data})
+		return h.HandleCommand(speaker, raw)
+	}
+	scope := gin.H{"store_id": storeID.String()}
+
+	tests := []struct {
+		name     string
+		id       string
+		action   string
+		data     gin.H
+		wantCode int // 0 表示期望 ACK
+	}{
+		{"添加任务", "c-1", models.CmdAddTask, gin.H{"store_id": storeID.String(), "product_id": pork.ID.String(), "text": "五花肉特价", "price": 12}, 0},
+		{"缺少必填参数", "c-2", models.CmdAddTask, scope, http.StatusBadRequest},
+		{"暂停", "c-3", models.CmdPause, scope, 0},
+		{"拉取快照", "c-4", models.CmdGetSnapshot, scope, 0},
+		{"操作其他门店", "c-5", models.CmdPause, gin.H{"store_id": otherStore.String()}, http.StatusForbidden},
+		{"无效的门店ID", "c-6", models.CmdResume, gin.H{"store_id": "abc"}, http.StatusBadRequest},
+		{"未知命令", "c-7", "REBOOT", scope, http.StatusBadRequest},
+		{"停止", "c-8", models.CmdStop, scope, 0},
+		{"会话已停止后暂停", "c-9", models.CmdPause, scope, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		reply := send(tt.id, tt.action, tt.data)
+		if tt.wantCode == 0 {
+			ack, ok := reply.Data.(models.CommandAckData)
+			if reply.Type != "ACK" || !ok || ack.ID != tt.id || ack.Action != tt.action {
+				t.Errorf("%s: reply = %+v, want ACK %s", tt.name, reply, tt.id)
+			}
+			continue
+		}
+		cmdErr, ok := reply.Data.(models.CommandErrorData)
+		if reply.Type != "ERROR" || !ok || cmdErr.ID != tt.id || cmdErr.Code != tt.wantCode {
+			t.Errorf("%s: reply = %+v, want ERROR %s %d", tt.name, reply, tt.id, tt.wantCode)
+		}
+	}
+
+	// 命令和 REST 接口共用处理逻辑，暂停的结果体现在快照里
+	send("c-10", models.CmdAddTask, gin.H{"store_id": storeID.String(), "product_id": pork.ID.String(), "text": "五花肉特价", "price": 12})
+	send("c-11", models.CmdPause, scope)
+	reply := send("c-12", models.CmdGetSnapshot, scope)
+	snapshot := reply.Data.(models.CommandAckData).Result.(gin.H)["tasks"].(*models.TasksSnapshotData)
+	if !snapshot.Paused || len(snapshot.Products) != 1 {
+		t.Errorf("快照 paused=%v 任务数=%d, want 暂停且 1 个任务", snapshot.Paused, len(snapshot.Products))
+	}
+
+	// 无法解析的命令
+	if reply := h.HandleCommand(speaker, []byte(`{"id": `)); reply.Type != "ERROR" {
+		t.Errorf("无法解析的命令 reply = %+v", reply)
+	}
+}
@@ -1,31 +1,163 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"hawker-backend/middleware"
+	"hawker-backend/repositories"
 	"hawker-backend/services"
+	"hawker-backend/utils"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin:     func(r *http.Request) bool { return true },
+	// 浏览器通过子协议携带令牌时，握手响应必须回传其中一个子协议，否则浏览器会断开
+	Subprotocols: []string{middleware.BearerSubprotocol},
 }
 
-func ServeWs(hub *services.Hub, c *gin.Context) {
+type WsHandler struct {
+	Hub    *services.Hub
+	Grants repositories.StoreGrantRepository
+	jwtKey string
+}
+
+func NewWsHandler(hub *services.Hub, grants repositories.StoreGrantRepository, jwtKey string) *WsHandler {
+	return &WsHandler{Hub: hub, Grants: grants, jwtKey: jwtKey}
+}
+
+// ServeWs 建立 WebSocket 连接，令牌的传法见 middleware.RequestToken
+// 握手时校验身份和每一个订阅，任何一个订阅无权访问都直接拒绝，不会升级连接
+func (h *WsHandler) ServeWs(c *gin.Context) {
+	token := middleware.RequestToken(c.Request)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	identity, err := h.authenticate(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的Token"})
+		return
+	}
+
+	rooms, status, err := h.authorizeRooms(c, identity)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("Upgrade Error:", err)
 		return
 	}
 
-	client := &services.Client{Hub: hub, Conn: conn, Send: make(chan []byte, 256)}
+	client := services.NewClient(h.Hub, conn, rooms, identity)
 	client.Hub.Register <- client
 
 	// 启动读写协程
 	go client.WritePump()
 	go client.ReadPump()
 }
+
+// authenticate 设备令牌（dev_ 开头）查设备授权，其余按账号 JWT 校验
+func (h *WsHandler) authenticate(token string) (services.ClientIdentity, error) {
+	if strings.HasPrefix(token, utils.DeviceTokenPrefix) {
+		device, err := h.Grants.FindDevice(utils.HashDeviceToken(token))
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("❌ 查询设备授权失败: %v", err)
+			}
+			return services.ClientIdentity{}, err
+		}
+		if err := h.Grants.TouchDevice(device.ID); err != nil {
+			log.Printf("⚠️ 更新设备连接时间失败 [%s]: %v", device.Name, err)
+		}
+		return services.ClientIdentity{DeviceID: device.ID, StoreID: device.StoreID, ZoneID: device.ZoneID}, nil
+	}
+
+	claims, err := utils.ParseToken(token, h.jwtKey)
+	if err != nil {
+		return services.ClientIdentity{}, err
+	}
+	return services.ClientIdentity{OwnerID: claims.OwnerID}, nil
+}
+
+// authorizeRooms 校验并生成订阅的房间：
+// /ws?store_id=S 接收门店下所有会话的消息（店主 App），需要是店主或被授权的店员
+// /ws?zone_id=A&zone_id=B 或 session_id=<store_id> 只接收指定会话的消息（分区音箱）
+// 设备只能订阅所属门店；限定了分区的设备只能订阅该分区的会话
+// 设备不带订阅参数时自动订阅自己的会话，账号不带订阅参数则收不到任何叫卖消息
+func (h *WsHandler) authorizeRooms(c *gin.Context, identity services.ClientIdentity) ([]string, int, error) {
+	storeIDs := c.QueryArray("store_id")
+	sessionIDs := append(c.QueryArray("session_id"), c.QueryArray("zone_id")...)
+
+	if identity.DeviceID != uuid.Nil && len(storeIDs) == 0 && len(sessionIDs) == 0 {
+		sessionID := identity.StoreID
+		if identity.ZoneID != nil {
+			sessionID = *identity.ZoneID
+		}
+		return []string{services.SessionRoom(sessionID.String())}, http.StatusOK, nil
+	}
+
+	rooms := make([]string, 0, len(storeIDs)+len(sessionIDs))
+	for _, id := range storeIDs {
+		storeID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("无效的门店ID: %s", id)
+		}
+		if status, err := checkAccess(h.Grants, identity, storeID, nil); err != nil {
+			return nil, status, err
+		}
+		rooms = append(rooms, services.StoreRoom(id))
+	}
+
+	for _, id := range sessionIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("无效的会话ID: %s", id)
+		}
+		storeID, zoneID, err := h.Grants.ResolveSession(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, http.StatusNotFound, fmt.Errorf("分区或门店不存在: %s", id)
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, fmt.Errorf("查询会话失败")
+		}
+		if status, err := checkAccess(h.Grants, identity, storeID, zoneID); err != nil {
+			return nil, status, err
+		}
+		rooms = append(rooms, services.SessionRoom(id))
+	}
+	return rooms, http.StatusOK, nil
+}
+
+// checkAccess 校验连接能否访问门店，订阅和 WebSocket 命令共用；zoneID 为空表示整个门店或门店默认会话
+func checkAccess(grants repositories.StoreGrantRepository, identity services.ClientIdentity, storeID uuid.UUID, zoneID *uuid.UUID) (int, error) {
+	if identity.DeviceID != uuid.Nil {
+		allowed := identity.StoreID == storeID &&
+			(identity.ZoneID == nil || (zoneID != nil && *zoneID == *identity.ZoneID))
+		if !allowed {
+			return http.StatusForbidden, fmt.Errorf("设备无权访问: %s", storeID)
+		}
+		return http.StatusOK, nil
+	}
+
+	allowed, err := grants.CanAccessStore(identity.OwnerID, storeID.String())
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("校验门店权限失败")
+	}
+	if !allowed {
+		return http.StatusForbidden, fmt.Errorf("无权访问门店: %s", storeID)
+	}
+	return http.StatusOK, nil
+}
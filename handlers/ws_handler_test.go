@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"hawker-backend/services"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCheckAccess(t *testing.T) {
+	owner, storeA, storeB := uuid.New(), uuid.New(), uuid.New()
+	zone1, zone2 := uuid.New(), uuid.New()
+	grants := &fakeGrants{access: map[string]bool{storeA.String(): true}}
+
+	wholeStore := services.ClientIdentity{DeviceID: uuid.New(), StoreID: storeA}
+	zoneOnly := services.ClientIdentity{DeviceID: uuid.New(), StoreID: storeA, ZoneID: &zone1}
+	staff := services.ClientIdentity{OwnerID: owner}
+
+	tests := []struct {
+		name     string
+		identity services.ClientIdentity
+		storeID  uuid.UUID
+		zoneID   *uuid.UUID
+		want     int
+	}{
+		{"门店设备访问门店默认会话", wholeStore, storeA, nil, http.StatusOK},
+		{"门店设备访问分区", wholeStore, storeA, &zone2, http.StatusOK},
+		{"门店设备访问其他门店", wholeStore, storeB, nil, http.StatusForbidden},
+		{"分区设备访问所属分区", zoneOnly, storeA, &zone1, http.StatusOK},
+		{"分区设备访问其他分区", zoneOnly, storeA, &zone2, http.StatusForbidden},
+		{"分区设备访问门店默认会话", zoneOnly, storeA, nil, http.StatusForbidden},
+		{"分区设备访问其他门店的同 ID 分区", zoneOnly, storeB, &zone1, http.StatusForbidden},
+		{"店主访问被授权的门店", staff, storeA, &zone2, http.StatusOK},
+		{"店主访问其他门店", staff, storeB, nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		status, err := checkAccess(grants, tt.identity, tt.storeID, tt.zoneID)
+		if status != tt.want || (err == nil) != (tt.want == http.StatusOK) {
+			t.Errorf("%s: status = %d, err = %v, want %d", tt.name, status, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"hawker-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ZoneHandler struct {
+	Repo      repositories.ZoneRepository
+	Scheduler *services.HawkingScheduler
+}
+
+func NewZoneHandler(repo repositories.ZoneRepository, scheduler *services.HawkingScheduler) *ZoneHandler {
+	return &ZoneHandler{Repo: repo, Scheduler: scheduler}
+}
+
+// CreateZone 在门店下新建播放分区
+func (h *ZoneHandler) CreateZone(c *gin.Context) {
+	storeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的门店ID"})
+		return
+	}
+
+	var req models.CreateZoneReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	zone := models.Zone{StoreID: storeID, Name: req.Name, VoiceType: req.VoiceType}
+	if err := h.Repo.Create(&zone); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建分区失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, zone)
+}
+
+// GetZones 获取门店下的所有分区，并附带每个分区当前是否有进行中的叫卖会话
+func (h *ZoneHandler) GetZones(c *gin.Context) {
+	zones, err := h.Repo.FindByStoreID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询分区失败"})
+		return
+	}
+
+	type zoneView struct {
+		models.Zone
+		Hawking bool `json:"hawking"` // 该分区是否有进行中的叫卖会话
+	}
+	views := make([]zoneView, 0, len(zones))
+	for _, zone := range zones {
+		views = append(views, zoneView{Zone: zone, Hawking: h.Scheduler.HasSession(zone.ID.String())})
+	}
+
+	c.JSON(http.StatusOK, views)
+}
@@ -0,0 +1,39 @@
+package logic
+
+import (
+	"hawker-backend/models"
+)
+
+// 候选文案的来源
+const (
+	ScriptModeRepeat = "repeat" // 复读机模式
+	ScriptModeSmart  = "smart"  // 智能描述模式
+)
+
+// ScriptCandidate 一条候选文案
+type ScriptCandidate struct {
+	Mode string `json:"mode"`
+	Text string `json:"text"`
+}
+
+// GenerateCandidates 生成最多 n 条互不相同的候选文案，供店主在合成前挑选
+// 复读机模式的文案是固定的，无论 n 是多少都只出一条（排在第一条）；
+// 其余由智能描述模式随机组合，组合不够时返回的数量会少于 n
+func GenerateCandidates(p models.Product, task *models.HawkingTask, n int) []ScriptCandidate {
+	candidates := make([]ScriptCandidate, 0, n)
+	seen := make(map[string]bool, n)
+	add := func(mode string, text string) {
+		if len(candidates) < n && !seen[text] {
+			seen[text] = true
+			candidates = append(candidates, ScriptCandidate{Mode: mode, Text: text})
+		}
+	}
+
+	add(ScriptModeRepeat, generateRepeatScript(p, task))
+
+	oralPrice, oralOriginalPrice := oralPrices(task)
+	for tries := 0; len(candidates) < n && tries < n*20; tries++ {
+		add(ScriptModeSmart, generateSmartScriptExtended(p, task, oralPrice, oralOriginalPrice))
+	}
+	return candidates
+}
@@ -0,0 +1,27 @@
+package logic
+
+import (
+	"hawker-backend/models"
+	"testing"
+)
+
+func TestGenerateCandidates(t *testing.T) {
+	product := models.Product{Name: "五花肉"}
+	task := &models.HawkingTask{Price: 16.8, OriginalPrice: 22, Unit: "斤"}
+
+	candidates := GenerateCandidates(product, task, 5)
+	if len(candidates) != 5 {
+		t.Fatalf("len(candidates) = %d, want 5", len(candidates))
+	}
+	if candidates[0].Mode != ScriptModeRepeat {
+		t.Errorf("第一条应为复读机模式，实际为 %s", candidates[0].Mode)
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if seen[c.Text] {
+			t.Errorf("候选文案重复: %s", c.Text)
+		}
+		seen[c.Text] = true
+	}
+}
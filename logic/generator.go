@@ -41,53 +41,64 @@ func GenerateScript(p models.Product, task *models.HawkingTask) string {
 	// 每次生成重新播种，确保真随机
 	rand.Seed(time.Now().UnixNano())
 
-	// 1. 口语化价格转换
-	oralPrice := formatPriceToOral(task.Price, task.Unit)
-	oralOriginalPrice := ""
+	// 策略选择：如果开启复读机模式
+	if task.UseRepeatMode {
+		return generateRepeatScript(p, task)
+	}
+
+	// 智能描述模式逻辑
+	oralPrice, oralOriginalPrice := oralPrices(task)
+	return generateSmartScriptExtended(p, task, oralPrice, oralOriginalPrice)
+}
+
+// oralPrices 口语化的现价和原价，原价不高于现价时为空
+func oralPrices(task *models.HawkingTask) (oralPrice string, oralOriginalPrice string) {
+	// 口语化价格转换
+	oralPrice = formatPriceToOral(task.Price, task.Unit)
 	// 🌟 只有当原价确实存在且大于现价时，才生成原价口语
 	if task.OriginalPrice > task.Price {
 		oralOriginalPrice = formatPriceToOral(task.OriginalPrice, task.Unit)
 	}
+	return oralPrice, oralOriginalPrice
+}
 
-	// 2. 确定时间语境
+// generateRepeatScript 复读机模式：品名和价格反复报，文案是固定的
+func generateRepeatScript(p models.Product, task *models.HawkingTask) string {
+	oralPrice, oralOriginalPrice := oralPrices(task)
+
+	// 确定时间语境
 	timeContext := "今天"
 	if time.Now().Hour() >= 17 {
 		timeContext = "晚上"
 	}
 
-	// 3. 策略选择：如果开启复读机模式
-	if task.UseRepeatMode {
-		label := p.MarketingLabel
-		if label == "" {
-			label = "新鲜的"
-		}
-
-		promo := task.PromotionTag
-		if promo == "" {
-			promo = "活动价"
-		}
+	label := p.MarketingLabel
+	if label == "" {
+		label = "新鲜的"
+	}
 
-		// --- 🌟 优化后的复读机模板 ---
-		// 情况 A: 有原价时，加入对比逻辑
-		if oralOriginalPrice != "" {
-			return fmt.Sprintf("%s %s，%s%s，平时都要卖 %s，%s%s 只要 %s！",
-				p.Name, oralPrice, // 第一遍报盘
-				label, p.Name, // 第二遍开始：定语+品名
-				oralOriginalPrice,             // 抛出原价做对比
-				timeContext, promo, oralPrice, // 给出现在的促销理由和价格
-			)
-		}
+	promo := task.PromotionTag
+	if promo == "" {
+		promo = "活动价"
+	}
 
-		// 情况 B: 无原价时，保持原来的简洁有力
-		return fmt.Sprintf("%s %s，%s%s，%s%s 只要 %s！",
-			p.Name, oralPrice,
-			label, p.Name,
-			timeContext, promo, oralPrice,
+	// --- 🌟 优化后的复读机模板 ---
+	// 情况 A: 有原价时，加入对比逻辑
+	if oralOriginalPrice != "" {
+		return fmt.Sprintf("%s %s，%s%s，平时都要卖 %s，%s%s 只要 %s！",
+			p.Name, oralPrice, // 第一遍报盘
+			label, p.Name, // 第二遍开始：定语+品名
+			oralOriginalPrice,             // 抛出原价做对比
+			timeContext, promo, oralPrice, // 给出现在的促销理由和价格
 		)
 	}
 
-	// 4. 智能描述模式逻辑
-	return generateSmartScriptExtended(p, task, oralPrice, oralOriginalPrice)
+	// 情况 B: 无原价时，保持原来的简洁有力
+	return fmt.Sprintf("%s %s，%s%s，%s%s 只要 %s！",
+		p.Name, oralPrice,
+		label, p.Name,
+		timeContext, promo, oralPrice,
+	)
 }
 
 // formatPriceToOral 将数字价格和单位转化为富有烟火气的口语
@@ -0,0 +1,33 @@
+package logic
+
+import "hawker-backend/models"
+
+// StockRule 根据库存决定商品的叫卖模式
+// 阈值以安全库存的倍数表示：库存见底时清货，库存充足时引流，其余回到常规
+type StockRule struct {
+	LowStockRatio float64
+	AbundantRatio float64
+}
+
+// Evaluate 计算商品应处的叫卖模式，ok 为 false 表示规则不适用，应保持原模式
+// 手动设置的促销模式不受库存影响；没有配置安全库存的商品也无从判断
+// 注意 ModeStop 是商品的默认值，并不代表店主手动停止，因此照常参与判断
+func (r StockRule) Evaluate(p models.Product) (mode models.HawkingMode, ok bool) {
+	if p.HawkingMode == models.ModePromotion {
+		return p.HawkingMode, false
+	}
+	if p.SafetyStock <= 0 {
+		return p.HawkingMode, false
+	}
+
+	stock := float64(p.CurrentStock)
+	safety := float64(p.SafetyStock)
+	switch {
+	case stock <= safety*r.LowStockRatio:
+		return models.ModeLowStock, true
+	case r.AbundantRatio > 0 && stock >= safety*r.AbundantRatio:
+		return models.ModeAbundant, true
+	default:
+		return models.ModeNormal, true
+	}
+}
@@ -0,0 +1,32 @@
+package logic
+
+import (
+	"hawker-backend/models"
+	"testing"
+)
+
+func TestStockRuleEvaluate(t *testing.T) {
+	rule := StockRule{LowStockRatio: 1, AbundantRatio: 3}
+
+	cases := []struct {
+		name     string
+		product  models.Product
+		wantMode models.HawkingMode
+		wantOK   bool
+	}{
+		{"低于安全库存", models.Product{HawkingMode: models.ModeNormal, SafetyStock: 10, CurrentStock: 8}, models.ModeLowStock, true},
+		{"正好等于安全库存", models.Product{HawkingMode: models.ModeNormal, SafetyStock: 10, CurrentStock: 10}, models.ModeLowStock, true},
+		{"库存充足", models.Product{HawkingMode: models.ModeNormal, SafetyStock: 10, CurrentStock: 30}, models.ModeAbundant, true},
+		{"补货后回到常规", models.Product{HawkingMode: models.ModeLowStock, SafetyStock: 10, CurrentStock: 15}, models.ModeNormal, true},
+		{"没有安全库存", models.Product{HawkingMode: models.ModeNormal, CurrentStock: 1}, models.ModeNormal, false},
+		{"手动促销不受影响", models.Product{HawkingMode: models.ModePromotion, SafetyStock: 10, CurrentStock: 1}, models.ModePromotion, false},
+		{"默认模式参与判断", models.Product{HawkingMode: models.ModeStop, SafetyStock: 10, CurrentStock: 1}, models.ModeLowStock, true},
+	}
+
+	for _, c := range cases {
+		mode, ok := rule.Evaluate(c.product)
+		if mode != c.wantMode || ok != c.wantOK {
+			t.Errorf("%s: Evaluate = (%v, %v), want (%v, %v)", c.name, mode, ok, c.wantMode, c.wantOK)
+		}
+	}
+}
@@ -3,12 +3,15 @@ package middleware
 import (
 	"fmt"
 	"hawker-backend/utils"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
+// 浏览器的 WebSocket 无法设置请求头，改用子协议携带令牌：new WebSocket(url, ["bearer", token])
+const BearerSubprotocol = "bearer"
+
 func AuthMiddleware(jwtKey string) gin.HandlerFunc {
 	fmt.Printf("[Auth] Key loaded, length: %d, prefix: %c\n", len(jwtKey), jwtKey[0])
 	return func(c *gin.Context) {
@@ -20,19 +23,8 @@ func AuthMiddleware(jwtKey string) gin.HandlerFunc {
 		}
 
 		tokenString := authHeader[7:] // 去掉 "Bearer "
-		claims := &utils.Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			// 1. 强制检查算法（推荐的安全做法）
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			// 2. 必须显式转换成 []byte
-			return []byte(jwtKey), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := utils.ParseToken(tokenString, jwtKey)
+		if err != nil {
 			c.JSON(401, gin.H{"error": "无效的Token"})
 			c.Abort()
 			return
@@ -43,3 +35,27 @@ func AuthMiddleware(jwtKey string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequestToken 取出 WebSocket 握手请求携带的令牌，依次尝试：
+// 1. Authorization: Bearer <token>（App、服务端）
+// 2. Sec-WebSocket-Protocol: bearer, <token>（浏览器），握手响应需回传 bearer 子协议
+// 3. ?access_token=<token>（无法设置请求头的音箱固件），会出现在访问日志里，只建议设备令牌使用
+func RequestToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		return authHeader[7:]
+	}
+
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	for i, p := range protocols {
+		if strings.EqualFold(p, BearerSubprotocol) && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+
+	return r.URL.Query().Get("access_token")
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"hawker-backend/repositories"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// StoreAccess 门店下的接口（/stores/:id/...）只允许店主本人或被授权的店员访问
+func StoreAccess(grants repositories.StoreGrantRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CheckStoreAccess(c, grants, c.Param("id")) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// HawkingStoreAccess 叫卖接口的门店放在查询参数或 JSON 请求体的 store_id 里，校验规则与 StoreAccess 相同
+// 读取请求体后会原样放回，后面的 ShouldBindJSON 不受影响
+func HawkingStoreAccess(grants repositories.StoreGrantRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		storeID := c.Query("store_id")
+		if storeID == "" && c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "读取请求失败"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+			var scope struct {
+				StoreID string `json:"store_id"`
+			}
+			_ = json.Unmarshal(body, &scope)
+			storeID = scope.StoreID
+		}
+		if storeID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "缺少门店ID"})
+			return
+		}
+		if !CheckStoreAccess(c, grants, storeID) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// CheckStoreAccess 校验当前店主能否访问门店，不能访问时写入错误并中止请求
+// 按 ID 操作单条记录（节目、广播）的接口先查出记录，再用它校验记录所属的门店
+func CheckStoreAccess(c *gin.Context, grants repositories.StoreGrantRepository, storeID string) bool {
+	id, err := uuid.Parse(storeID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "无效的门店ID"})
+		return false
+	}
+
+	ownerID := c.MustGet("current_owner_id").(uuid.UUID)
+	allowed, err := grants.CanAccessStore(ownerID, id.String())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "校验门店权限失败"})
+		return false
+	}
+	if !allowed {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "无权访问该门店"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"hawker-backend/repositories"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type fakeGrants struct {
+	repositories.StoreGrantRepository
+	stores map[string]bool // 当前店主能访问的门店
+}
+
+func (g *fakeGrants) CanAccessStore(ownerID uuid.UUID, storeID string) (bool, error) {
+	return g.stores[storeID], nil
+}
+
+func TestStoreAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mine, other := uuid.NewString(), uuid.NewString()
+	grants := &fakeGrants{stores: map[string]bool{mine: true}}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("current_owner_id", uuid.New()) })
+	r.GET("/stores/:id/zones", StoreAccess(grants), func(c *gin.Context) { c.Status(http.StatusOK) })
+	hawking := r.Group("/hawking", HawkingStoreAccess(grants))
+	hawking.GET("/tasks", func(c *gin.Context) { c.Status(http.StatusOK) })
+	hawking.POST("/tasks", func(c *gin.Context) {
+		// 中间件读过请求体后，handler 仍能正常解析
+		var req struct {
+			StoreID string `json:"store_id" binding:"required"`
+			Text    string `json:"text" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"自己的门店", "GET", "/stores/" + mine + "/zones", "", http.StatusOK},
+		{"别人的门店", "GET", "/stores/" + other + "/zones", "", http.StatusForbidden},
+		{"无效的门店ID", "GET", "/stores/abc/zones", "", http.StatusBadRequest},
+		{"查询参数里的门店", "GET", "/hawking/tasks?store_id=" + mine, "", http.StatusOK},
+		{"查询参数里别人的门店", "GET", "/hawking/tasks?store_id=" + other, "", http.StatusForbidden},
+		{"请求体里的门店", "POST", "/hawking/tasks", `{"store_id":"` + mine + `","text":"五花肉"}`, http.StatusCreated},
+		{"请求体里别人的门店", "POST", "/hawking/tasks", `{"store_id":"` + other + `","text":"五花肉"}`, http.StatusForbidden},
+		{"缺少门店", "POST", "/hawking/tasks", `{"text":"五花肉"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
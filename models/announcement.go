@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 广播状态
+const (
+	AnnouncementPlaying   = "playing"   // 已合成，正在（重复）播放
+	AnnouncementDone      = "done"      // 所有重复次数播放完毕
+	AnnouncementCancelled = "cancelled" // 被手动取消
+	AnnouncementFailed    = "failed"    // 合成失败
+)
+
+// Announcement 门店的临时广播（打烊通知、寻人启事、收银台排队提示等）
+// 插播在正常叫卖之前，每条都会记录下来便于追溯
+type Announcement struct {
+	Base
+	StoreID           uuid.UUID  `gorm:"type:uuid;index;not null" json:"store_id"`
+	ZoneID            string     `gorm:"type:varchar(64)" json:"zone_id"` // 为空表示全店广播
+	Text              string     `gorm:"type:text;not null" json:"text"`
+	VoiceType         string     `gorm:"type:varchar(50)" json:"voice_type"`
+	AudioURL          string     `gorm:"type:varchar(255)" json:"audio_url"`
+	Repeat            int        `gorm:"default:1" json:"repeat"` // 总共播放几次
+	RepeatIntervalSec int        `json:"repeat_interval_sec"`     // 两次播放之间的间隔（秒）
+	PlayedCount       int        `json:"played_count"`            // 已播放次数
+	Status            string     `gorm:"type:varchar(20)" json:"status"`
+	Error             string     `gorm:"type:text" json:"error"`
+	FinishedAt        *time.Time `json:"finished_at"`
+}
+
+type AnnouncementReq struct {
+	StoreID           string `json:"store_id" binding:"required"`
+	ZoneID            string `json:"zone_id"` // 可选：只在某个分区广播
+	Text              string `json:"text" binding:"required"`
+	VoiceType         string `json:"voice_type"`          // 不传则使用会话默认音色
+	Repeat            int    `json:"repeat"`              // 播放次数，默认 1 次
+	RepeatIntervalSec int    `json:"repeat_interval_sec"` // 重复间隔，默认播完立即重复
+}
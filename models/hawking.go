@@ -1,6 +1,11 @@
 package models
 
+import "time"
+
 type HawkingTask struct {
+	ID            string  `gorm:"type:varchar(36)" json:"task_id"` // 任务 ID，同一商品可以有多个任务（如价格喊法 + 做法介绍）
+	Position      int     `json:"position"`                        // 在 Session 中的排列顺序，从 0 开始
+	Revision      int64   `json:"revision"`                        // 最近一次变化时 Session 的修订号，用于增量同步
 	ProductID     string  `json:"product_id"`
 	AudioURL      string  `json:"audio_url"`
 	Text          string  `json:"text"`        // 生成的、锁定的、用于合成的最终文本
@@ -9,17 +14,55 @@ type HawkingTask struct {
 	Price         float64 `json:"price"`          // 临时现价
 	OriginalPrice float64 `json:"original_price"` // 临时原价
 	Unit          string  `json:"unit"`           // 存储本次叫卖的特定单位
-	VoiceType     string  `json:"voice_type"`
+	VoiceType     string  `json:"voice_type"`     // 实际使用的音色
+	PinnedVoice   bool    `json:"pinned_voice"`   // true：固定使用自己的音色，不随 Session 默认音色切换
 
 	// --- 新增条件促销字段 ---
 	MinQty        float64 `json:"min_qty"`        // 触发优惠的门槛数量，如 2
 	ConditionUnit string  `json:"condition_unit"` // 门槛单位，如 "斤" 或 "条"
 
-	// 关键：标记该任务是否已经完成合成并下发过
-	IsSynthesized bool
+	// 关键：任务的生命周期状态，只能通过调度器按规则跳转
+	Status          TaskStatus `json:"status"`
+	StatusChangedAt time.Time  `json:"status_changed_at"` // 最近一次状态变化的时间
+
+	// --- 合成失败与重试 ---
+	Attempts    int        `json:"attempts"`      // 已失败的合成次数
+	LastError   string     `json:"last_error"`    // 最近一次失败原因，status 为 failed 时即最终原因
+	NextRetryAt *time.Time `json:"next_retry_at"` // 下一次重试时间
 
 	PromotionTag  string `json:"promotion_tag"` // "特价", "秒杀"
 	UseRepeatMode bool   `json:"use_repeat_mode"`
+
+	// --- 轮播参数（AddTask 时从 Product 继承，可被请求覆盖） ---
+	Weight       int        `json:"weight"`         // 权重：越高出现越频繁
+	Priority     int        `json:"priority"`       // 优先级：高优先级任务插队
+	IntervalSec  int        `json:"interval_sec"`   // 播完后至少间隔多少秒才能再播
+	LastPlayedAt *time.Time `json:"last_played_at"` // 上次被轮播引擎选中的时间
+
+	// --- 生效时间 ---
+	TaskWindow `gorm:"embedded"`
+	Active     bool `json:"active"` // 当前是否在生效时段内，由调度器定时刷新
+
+	// --- 来源促销（由促销场次导入时才有值） ---
+	PromotionID string `json:"promotion_id"`
+	SortOrder   int    `json:"sort_order"` // 促销明细的排序，同等条件下按此顺序轮播
+
+	// --- 来源节目（由节目自动加载时才有值） ---
+	ProgramID string `json:"program_id"`
+
+	// --- 打烊降价：到点后按 BasePrice 的比例自动调价并重新生成文案 ---
+	Markdowns         []MarkdownStep `gorm:"serializer:json" json:"markdowns"`
+	BasePrice         float64        `json:"base_price"`          // 降价前的现价
+	BaseOriginalPrice float64        `json:"base_original_price"` // 降价前设置的原价
+	MarkdownRatio     float64        `json:"markdown_ratio"`      // 当前已执行的折扣，0 或 1 表示未降价
+}
+
+// CooldownRemaining 距离该任务可以再次播放还需等待的时长，<= 0 表示可以播放
+func (t *HawkingTask) CooldownRemaining(now time.Time) time.Duration {
+	if t.LastPlayedAt == nil || t.IntervalSec <= 0 {
+		return 0
+	}
+	return t.LastPlayedAt.Add(time.Duration(t.IntervalSec) * time.Second).Sub(now)
 }
 
 type HawkingIntro struct {
@@ -40,7 +83,9 @@ type TaskBundle struct {
 }
 
 type AddTaskReq struct {
+	TaskID        string  `json:"task_id"` // 传入已有任务的 ID 表示修改该任务，不传则新增一个任务
 	StoreID       string  `json:"store_id" binding:"required"`
+	ZoneID        string  `json:"zone_id"` // 可选：投放到门店下的某个分区，不传则使用门店默认会话
 	ProductID     string  `json:"product_id" binding:"required"`
 	Text          string  `json:"text"`           // 用户完全自定义的文案
 	Price         float64 `json:"price"`          // 现价
@@ -52,12 +97,86 @@ type AddTaskReq struct {
 	ConditionUnit string  `json:"condition_unit"` // 门槛单位，如 "斤" 或 "条"
 
 	VoiceType string `json:"voice_type"` // 👈 用户选定的音色，如 "sunny_boy"
+	PinVoice  bool   `json:"pin_voice"`  // 为该任务固定 voice_type，否则跟随 Session 默认音色
 	IntroID   string `json:"intro_id"`   // 👈 用户指定的开场白 ID，"none" 表示不要
 
 	PromotionTag string `json:"promotion_tag"` // "特价", "秒杀"
 
 	// UseRepeatMode: 是否默认开启“复读机”喊法
 	UseRepeatMode bool `gorm:"default:true" json:"use_repeat_mode"`
+
+	// 轮播参数，不传（0）则使用商品上配置的值
+	Weight      int `json:"weight"`
+	Priority    int `json:"priority"`
+	IntervalSec int `json:"interval_sec"`
+
+	// 可选的生效时间：start_at/end_at 或每日时段 daily_start/daily_end
+	TaskWindow
+
+	PromotionID string `json:"promotion_id"` // 来源促销场次
+	SortOrder   int    `json:"sort_order"`
+	ProgramID   string `json:"program_id"` // 来源节目
+
+	// 可选的打烊降价台阶，如 [{"at":"19:00","ratio":0.9},{"at":"20:00","ratio":0.75}]
+	Markdowns []MarkdownStep `json:"markdowns"`
+}
+
+// BatchTaskReq 批量添加/整体替换任务，任务中的 store_id、zone_id 以外层为准
+type BatchTaskReq struct {
+	StoreID   string       `json:"store_id" binding:"required"`
+	ZoneID    string       `json:"zone_id"`
+	VoiceType string       `json:"voice_type"` // 任务未指定音色时使用
+	Tasks     []AddTaskReq `json:"tasks"`
+}
+
+// BatchRemoveReq 批量移除任务：按任务 ID 移除单个任务，按商品 ID 移除该商品的所有任务
+type BatchRemoveReq struct {
+	StoreID    string   `json:"store_id" binding:"required"`
+	ZoneID     string   `json:"zone_id"`
+	TaskIDs    []string `json:"task_ids"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+// ReorderTasksReq 调整任务顺序，task_ids 为拖动后的完整顺序，未列出的任务依次排在后面
+type ReorderTasksReq struct {
+	StoreID string   `json:"store_id" binding:"required"`
+	ZoneID  string   `json:"zone_id"`
+	TaskIDs []string `json:"task_ids" binding:"required"`
+}
+
+// ScriptPreviewReq 文案预览：参数与添加任务一致，只生成文案不合成
+type ScriptPreviewReq struct {
+	AddTaskReq
+	Count int `json:"count"` // 需要的候选条数，默认 5；复读机模式的文案只有一条
+}
+
+// SessionStateReq 暂停、恢复、停止叫卖会话
+type SessionStateReq struct {
+	StoreID string `json:"store_id" binding:"required"`
+	ZoneID  string `json:"zone_id"`
+}
+
+// SwitchVoiceReq 切换会话默认音色，固定了音色的任务不受影响
+type SwitchVoiceReq struct {
+	StoreId string `json:"store_id"`
+	ZoneID  string `json:"zone_id"`
+	VoiceID string `json:"voice_id"`
+}
+
+// SetTaskVoiceReq 为单个任务固定/取消固定音色
+type SetTaskVoiceReq struct {
+	StoreID   string `json:"store_id" binding:"required"`
+	ZoneID    string `json:"zone_id"`
+	VoiceType string `json:"voice_type"` // pinned 为 true 时必填
+	Pinned    bool   `json:"pinned"`     // false 表示取消固定，回到 Session 默认音色
+}
+
+// LoadPromotionReq 将促销场次一键导入叫卖
+type LoadPromotionReq struct {
+	StoreID       string `json:"store_id" binding:"required"`
+	ZoneID        string `json:"zone_id"`
+	VoiceType     string `json:"voice_type"`
+	UseRepeatMode bool   `json:"use_repeat_mode"`
 }
 
 type SyncIntroReq struct {
@@ -90,8 +209,30 @@ const (
 )
 
 type TasksSnapshotData struct {
+	SessionID string `json:"session_id"`
+	Paused    bool   `json:"paused"` // 会话已暂停：任务和音频都保留，但不下发 HAWKING_NEXT
+	// 快照对应的会话实例和修订号，客户端保存下来用于增量同步
+	Epoch    string `json:"epoch"`
+	Revision int64  `json:"revision"`
 	// 候选开场白池：客户端根据当前正在播的任务音色从这里面选
-	IntroPool []*HawkingIntro `json:"intro_pool"`
+	IntroPool        []*HawkingIntro `json:"intro_pool"`
+	IntroPoolVersion string          `json:"intro_pool_version"`
 	// 所有的任务
 	Products []*HawkingTask `json:"products"`
 }
+
+// TasksDeltaData 增量同步结果：客户端把 Changed 按 task_id 合并进本地列表，并删掉 Removed 中的任务
+// Full 为 true 时（会话已重建、修订号太旧等）Changed 是完整列表，客户端需要整体替换
+type TasksDeltaData struct {
+	SessionID string         `json:"session_id"`
+	Epoch     string         `json:"epoch"`
+	Since     int64          `json:"since"`
+	Revision  int64          `json:"revision"`
+	Full      bool           `json:"full"`
+	Paused    bool           `json:"paused"`
+	Changed   []*HawkingTask `json:"changed"`
+	Removed   []string       `json:"removed"`
+	// 开场白池只在客户端持有的版本过期时下发
+	IntroPool        []*HawkingIntro `json:"intro_pool,omitempty"`
+	IntroPoolVersion string          `json:"intro_pool_version"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MarkdownStep 打烊前自动降价的一个台阶：每天到 At 之后，按原价的 Ratio 出售
+type MarkdownStep struct {
+	At    string  `json:"at"`    // 每日时间，如 "19:00"
+	Ratio float64 `json:"ratio"` // 折扣比例，如 0.9 表示九折
+}
+
+// ValidateMarkdowns 校验降价台阶：时间格式正确、比例在 (0, 1) 之间且不重复
+func ValidateMarkdowns(steps []MarkdownStep) error {
+	seen := make(map[int]bool, len(steps))
+	for _, step := range steps {
+		minute, err := parseClock(step.At)
+		if err != nil {
+			return err
+		}
+		if seen[minute] {
+			return fmt.Errorf("降价时间重复: %s", step.At)
+		}
+		seen[minute] = true
+		if step.Ratio <= 0 || step.Ratio >= 1 {
+			return errors.New("降价比例必须在 0 到 1 之间")
+		}
+	}
+	return nil
+}
+
+// MarkdownRatioAt 当天 now 时刻应执行的折扣，还没到第一个台阶时返回 1（原价）
+// 按解析后的分钟比较，"9:00" 这种不补零的时间也能排在 "19:00" 之前
+func MarkdownRatioAt(steps []MarkdownStep, now time.Time) float64 {
+	current := now.Hour()*60 + now.Minute()
+	ratio, latest := 1.0, -1
+	for _, step := range steps {
+		minute, err := parseClock(step.At)
+		if err != nil || minute > current || minute <= latest {
+			continue
+		}
+		ratio, latest = step.Ratio, minute
+	}
+	return ratio
+}
+
+// MarkdownPrices 按折扣计算现价和对比用的原价
+// 现价四舍五入到角，方便口播；原价优先沿用任务设置的原价，没有则用打折前的价格
+func MarkdownPrices(basePrice float64, baseOriginalPrice float64, ratio float64) (price float64, originalPrice float64) {
+	if ratio >= 1 {
+		return basePrice, baseOriginalPrice
+	}
+	price = math.Round(basePrice*ratio*10) / 10
+	originalPrice = baseOriginalPrice
+	if originalPrice <= 0 {
+		originalPrice = basePrice
+	}
+	return price, originalPrice
+}
+
+// CurrentMarkdownRatio 任务当前执行中的折扣，未降价时为 1
+func (t *HawkingTask) CurrentMarkdownRatio() float64 {
+	if t.MarkdownRatio == 0 {
+		return 1
+	}
+	return t.MarkdownRatio
+}
+
+// ApplyMarkdown 按当前时间更新任务的价格，价格有变化时返回 true（需要重新生成文案）
+func (t *HawkingTask) ApplyMarkdown(now time.Time) bool {
+	if len(t.Markdowns) == 0 {
+		return false
+	}
+	ratio := MarkdownRatioAt(t.Markdowns, now)
+	if ratio == t.CurrentMarkdownRatio() {
+		return false
+	}
+	t.Price, t.OriginalPrice = MarkdownPrices(t.BasePrice, t.BaseOriginalPrice, ratio)
+	t.MarkdownRatio = ratio
+	return true
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHawkingTaskApplyMarkdown(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+	task := &HawkingTask{
+		Price:     12,
+		BasePrice: 12,
+		Markdowns: []MarkdownStep{{At: "20:30", Ratio: 0.6}, {At: "19:00", Ratio: 0.9}, {At: "20:00", Ratio: 0.75}},
+	}
+
+	steps := []struct {
+		now          time.Time
+		wantChanged  bool
+		wantPrice    float64
+		wantOriginal float64
+	}{
+		{at(18, 0), false, 12, 0},
+		{at(19, 0), true, 10.8, 12},
+		{at(19, 30), false, 10.8, 12},
+		{at(20, 0), true, 9, 12},
+		{at(20, 45), true, 7.2, 12},
+		{at(6, 0), true, 12, 0}, // 第二天早上恢复原价
+	}
+
+	for _, step := range steps {
+		changed := task.ApplyMarkdown(step.now)
+		if changed != step.wantChanged || task.Price != step.wantPrice || task.OriginalPrice != step.wantOriginal {
+			t.Errorf("%s: changed=%v price=%v original=%v, want %v %v %v",
+				step.now.Format(ClockLayout), changed, task.Price, task.OriginalPrice, step.wantChanged, step.wantPrice, step.wantOriginal)
+		}
+	}
+}
+
+func TestMarkdownRatioAtUnpaddedHour(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+	// 按字符串排序时 "19:00" 会排在 "9:00" 前面
+	steps := []MarkdownStep{{At: "19:00", Ratio: 0.6}, {At: "9:00", Ratio: 0.9}}
+
+	tests := []struct {
+		now  time.Time
+		want float64
+	}{
+		{at(8, 59), 1},
+		{at(9, 0), 0.9},
+		{at(18, 59), 0.9},
+		{at(19, 0), 0.6},
+		{at(23, 0), 0.6},
+	}
+	for _, tt := range tests {
+		if got := MarkdownRatioAt(steps, tt.now); got != tt.want {
+			t.Errorf("%s: ratio = %v, want %v", tt.now.Format(ClockLayout), got, tt.want)
+		}
+	}
+}
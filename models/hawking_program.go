@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HawkingProgram 叫卖节目：一组预先保存好的叫卖任务，按每周时段自动上线/下线
+// 例如“早市蔬菜”周一到周日 06:00-11:00，“晚市清仓”每天 17:00-21:00
+type HawkingProgram struct {
+	Base
+	StoreID       uuid.UUID `gorm:"type:uuid;index;not null" json:"store_id"`
+	ZoneID        string    `gorm:"type:varchar(64)" json:"zone_id"` // 为空表示门店默认会话
+	Name          string    `gorm:"type:varchar(100);not null" json:"name"`
+	VoiceType     string    `gorm:"type:varchar(50)" json:"voice_type"` // 节目音色，为空则跟随会话默认音色
+	UseRepeatMode bool      `json:"use_repeat_mode"`
+	Enabled       bool      `json:"enabled"`
+
+	// 每周时段：Weekdays 为 0(周日) - 6(周六)，为空表示每天
+	Weekdays   []int  `gorm:"serializer:json" json:"weekdays"`
+	DailyStart string `gorm:"type:varchar(5);not null" json:"daily_start"` // 如 "06:00"
+	DailyEnd   string `gorm:"type:varchar(5);not null" json:"daily_end"`   // 如 "11:00"，小于开始时间表示跨天
+
+	Items []ProgramItem `gorm:"serializer:json" json:"items"`
+}
+
+// ProgramItem 节目中的一条任务定义，字段含义与 AddTaskReq 一致
+type ProgramItem struct {
+	ProductID     string  `json:"product_id"`
+	Text          string  `json:"text"` // 为空则自动生成文案
+	Price         float64 `json:"price"`
+	OriginalPrice float64 `json:"original_price"`
+	Unit          string  `json:"unit"`
+	MinQty        float64 `json:"min_qty"`
+	ConditionUnit string  `json:"condition_unit"`
+	PromotionTag  string  `json:"promotion_tag"`
+	VoiceType     string  `json:"voice_type"` // 单独指定音色，为空则使用节目音色
+	Weight        int     `json:"weight"`
+	Priority      int     `json:"priority"`
+	IntervalSec   int     `json:"interval_sec"`
+	SortOrder     int     `json:"sort_order"`
+}
+
+// ProgramReq 新建/修改节目
+type ProgramReq struct {
+	ZoneID        string        `json:"zone_id"`
+	Name          string        `json:"name" binding:"required"`
+	VoiceType     string        `json:"voice_type"`
+	UseRepeatMode bool          `json:"use_repeat_mode"`
+	Enabled       *bool         `json:"enabled"` // 不传默认启用
+	Weekdays      []int         `json:"weekdays"`
+	DailyStart    string        `json:"daily_start" binding:"required"`
+	DailyEnd      string        `json:"daily_end" binding:"required"`
+	Items         []ProgramItem `json:"items" binding:"required"`
+}
+
+// Validate 校验节目的时段和任务定义
+func (p *HawkingProgram) Validate() error {
+	if err := (TaskWindow{DailyStart: p.DailyStart, DailyEnd: p.DailyEnd}).Validate(time.Time{}); err != nil {
+		return err
+	}
+	for _, day := range p.Weekdays {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("weekdays 取值应为 0-6: %d", day)
+		}
+	}
+	if len(p.Items) == 0 {
+		return errors.New("节目至少需要一个任务")
+	}
+	for _, item := range p.Items {
+		if item.ProductID == "" {
+			return errors.New("任务缺少 product_id")
+		}
+	}
+	return nil
+}
+
+// OccurrenceAt 返回 now 所处的这一场节目的起止时间，不在任何一场内时 ok 为 false
+// 跨天的节目（如周五 22:00 - 02:00）按开始那天的星期计算
+func (p *HawkingProgram) OccurrenceAt(now time.Time) (start time.Time, end time.Time, ok bool) {
+	from, err := parseClock(p.DailyStart)
+	if err != nil {
+		return
+	}
+	to, err := parseClock(p.DailyEnd)
+	if err != nil {
+		return
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// 昨天开始的跨天场次可能还没结束，所以今天和昨天都要检查
+	for _, day := range []time.Time{today, today.AddDate(0, 0, -1)} {
+		if !p.runsOn(day.Weekday()) {
+			continue
+		}
+		start = day.Add(time.Duration(from) * time.Minute)
+		end = day.Add(time.Duration(to) * time.Minute)
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func (p *HawkingProgram) runsOn(day time.Weekday) bool {
+	if len(p.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range p.Weekdays {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHawkingProgramOccurrenceAt(t *testing.T) {
+	// 2026-03-06 是周五
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, time.Local)
+	}
+	morning := &HawkingProgram{DailyStart: "06:00", DailyEnd: "11:00", Weekdays: []int{1, 2, 3, 4, 5}}
+	lateNight := &HawkingProgram{DailyStart: "22:00", DailyEnd: "02:00", Weekdays: []int{5}}
+
+	cases := []struct {
+		name      string
+		program   *HawkingProgram
+		now       time.Time
+		want      bool
+		wantStart time.Time
+	}{
+		{"工作日早市", morning, at(6, 7, 30), true, at(6, 6, 0)},
+		{"早市结束", morning, at(6, 11, 0), false, time.Time{}},
+		{"周末不上", morning, at(7, 7, 30), false, time.Time{}},
+		{"周五深夜", lateNight, at(6, 23, 0), true, at(6, 22, 0)},
+		{"跨到周六凌晨", lateNight, at(7, 1, 30), true, at(6, 22, 0)},
+		{"周六深夜不上", lateNight, at(7, 23, 0), false, time.Time{}},
+		{"每天", &HawkingProgram{DailyStart: "17:00", DailyEnd: "21:00"}, at(8, 18, 0), true, at(8, 17, 0)},
+	}
+
+	for _, c := range cases {
+		start, _, ok := c.program.OccurrenceAt(c.now)
+		if ok != c.want || !start.Equal(c.wantStart) {
+			t.Errorf("%s: OccurrenceAt = (%v, %v), want (%v, %v)", c.name, start, ok, c.wantStart, c.want)
+		}
+	}
+}
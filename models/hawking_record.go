@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// HawkingSessionRecord 叫卖会话的持久化记录
+// 服务重启后，调度器根据这张表恢复每个门店的 Session
+type HawkingSessionRecord struct {
+	ID           string    `gorm:"type:varchar(64);primaryKey" json:"id"` // 即 SessionID
+	StoreID      string    `gorm:"type:varchar(64);index" json:"store_id"`
+	ZoneID       string    `gorm:"type:varchar(64)" json:"zone_id"` // 为空表示门店默认会话
+	VoiceType    string    `gorm:"type:varchar(50)" json:"voice_type"`
+	VoiceVersion int       `gorm:"default:0" json:"voice_version"`
+	Paused       bool      `gorm:"default:false" json:"paused"` // 暂停状态，重启后保持
+	Epoch        string    `gorm:"type:varchar(36)" json:"epoch"`
+	Revision     int64     `gorm:"default:0" json:"revision"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	Tasks []HawkingTaskRecord `gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE" json:"tasks"`
+}
+
+// HawkingTaskRecord 叫卖任务的持久化记录
+// 直接内嵌 HawkingTask，锁定的文案、价格、音频地址、合成状态都原样落库
+type HawkingTaskRecord struct {
+	Base
+	SessionID string      `gorm:"type:varchar(64);index;not null" json:"session_id"`
+	Task      HawkingTask `gorm:"embedded;embeddedPrefix:task_" json:"task"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ClockLayout 每日时段使用的时间格式，如 "17:00"
+const ClockLayout = "15:04"
+
+// TaskWindow 任务的生效时间设置，全部为空表示一直有效
+type TaskWindow struct {
+	StartAt    *time.Time `json:"start_at"`    // 绝对开始时间
+	EndAt      *time.Time `json:"end_at"`      // 绝对结束时间，过了之后任务会被自动移除
+	DailyStart string     `json:"daily_start"` // 每日开始时间，如 "17:00"
+	DailyEnd   string     `json:"daily_end"`   // 每日结束时间，如 "21:00"，小于开始时间表示跨天
+}
+
+// Validate 校验时间设置是否合法
+func (w TaskWindow) Validate(now time.Time) error {
+	if w.EndAt != nil && !w.EndAt.After(now) {
+		return errors.New("end_at 必须晚于当前时间")
+	}
+	if w.StartAt != nil && w.EndAt != nil && !w.EndAt.After(*w.StartAt) {
+		return errors.New("end_at 必须晚于 start_at")
+	}
+	if (w.DailyStart == "") != (w.DailyEnd == "") {
+		return errors.New("daily_start 和 daily_end 必须同时提供")
+	}
+	if w.DailyStart != "" {
+		if _, err := parseClock(w.DailyStart); err != nil {
+			return err
+		}
+		if _, err := parseClock(w.DailyEnd); err != nil {
+			return err
+		}
+		if w.DailyStart == w.DailyEnd {
+			return errors.New("daily_start 和 daily_end 不能相同")
+		}
+	}
+	return nil
+}
+
+// Expired 是否已经过了绝对结束时间
+func (w TaskWindow) Expired(now time.Time) bool {
+	return w.EndAt != nil && !now.Before(*w.EndAt)
+}
+
+// ActiveAt 在给定时刻任务是否处于生效状态
+func (w TaskWindow) ActiveAt(now time.Time) bool {
+	if w.Expired(now) {
+		return false
+	}
+	if w.StartAt != nil && now.Before(*w.StartAt) {
+		return false
+	}
+	if w.DailyStart == "" {
+		return true
+	}
+	return InDailyRange(now, w.DailyStart, w.DailyEnd)
+}
+
+// InDailyRange 判断 now 是否落在每日时段 [start, end) 内，支持跨天（如 22:00 - 02:00）
+func InDailyRange(now time.Time, start string, end string) bool {
+	from, err := parseClock(start)
+	if err != nil {
+		return false
+	}
+	to, err := parseClock(end)
+	if err != nil {
+		return false
+	}
+	current := now.Hour()*60 + now.Minute()
+	if from < to {
+		return current >= from && current < to
+	}
+	return current >= from || current < to
+}
+
+// parseClock 将 "HH:MM" 解析为当天的分钟数
+func parseClock(value string) (int, error) {
+	t, err := time.Parse(ClockLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式错误: %s，应为 HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
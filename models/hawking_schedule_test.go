@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskWindowActiveAt(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+	end := day(21, 0)
+
+	cases := []struct {
+		name   string
+		window TaskWindow
+		now    time.Time
+		want   bool
+	}{
+		{"无限制", TaskWindow{}, day(3, 0), true},
+		{"结束前", TaskWindow{EndAt: &end}, day(20, 59), true},
+		{"到达结束时间", TaskWindow{EndAt: &end}, day(21, 0), false},
+		{"每日时段内", TaskWindow{DailyStart: "17:00", DailyEnd: "21:00"}, day(18, 30), true},
+		{"每日时段外", TaskWindow{DailyStart: "17:00", DailyEnd: "21:00"}, day(9, 0), false},
+		{"跨天时段-深夜", TaskWindow{DailyStart: "22:00", DailyEnd: "02:00"}, day(23, 0), true},
+		{"跨天时段-凌晨", TaskWindow{DailyStart: "22:00", DailyEnd: "02:00"}, day(1, 59), true},
+		{"跨天时段-白天", TaskWindow{DailyStart: "22:00", DailyEnd: "02:00"}, day(12, 0), false},
+	}
+
+	for _, c := range cases {
+		if got := c.window.ActiveAt(c.now); got != c.want {
+			t.Errorf("%s: ActiveAt = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTaskWindowValidate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	past := now.Add(-time.Hour)
+
+	if err := (TaskWindow{EndAt: &past}).Validate(now); err == nil {
+		t.Error("过去的 end_at 应该校验失败")
+	}
+	if err := (TaskWindow{DailyStart: "17:00"}).Validate(now); err == nil {
+		t.Error("只提供 daily_start 应该校验失败")
+	}
+	if err := (TaskWindow{DailyStart: "25:00", DailyEnd: "26:00"}).Validate(now); err == nil {
+		t.Error("非法时间格式应该校验失败")
+	}
+	if err := (TaskWindow{DailyStart: "17:00", DailyEnd: "21:00"}).Validate(now); err != nil {
+		t.Errorf("合法时段不应报错: %v", err)
+	}
+}
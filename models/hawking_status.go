@@ -0,0 +1,34 @@
+package models
+
+// TaskStatus 叫卖任务的生命周期状态
+type TaskStatus string
+
+const (
+	TaskQueued       TaskStatus = "queued"       // 等待合成（包括失败后退避等待重试）
+	TaskSynthesizing TaskStatus = "synthesizing" // 正在调用 TTS 合成
+	TaskReady        TaskStatus = "ready"        // 音频已就绪，可以播放
+	TaskFailed       TaskStatus = "failed"       // 多次重试后放弃，或供应商明确拒绝
+	TaskSuperseded   TaskStatus = "superseded"   // 音色切换后旧音频作废，随即重新排队或命中缓存
+	TaskCancelled    TaskStatus = "cancelled"    // 任务被移除（手动移除或到期），终态
+)
+
+// taskTransitions 允许的状态跳转
+var taskTransitions = map[TaskStatus][]TaskStatus{
+	"":               {TaskQueued, TaskReady},
+	TaskQueued:       {TaskSynthesizing, TaskReady, TaskSuperseded, TaskCancelled},
+	TaskSynthesizing: {TaskReady, TaskQueued, TaskFailed, TaskSuperseded, TaskCancelled},
+	TaskReady:        {TaskQueued, TaskSuperseded, TaskCancelled},
+	TaskFailed:       {TaskQueued, TaskSuperseded, TaskCancelled},
+	TaskSuperseded:   {TaskQueued, TaskReady, TaskCancelled},
+	TaskCancelled:    {},
+}
+
+// CanTransitionTo 判断是否允许从当前状态跳转到目标状态
+func (s TaskStatus) CanTransitionTo(to TaskStatus) bool {
+	for _, allowed := range taskTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
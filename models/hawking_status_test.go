@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestTaskStatusCanTransitionTo(t *testing.T) {
+	cases := []struct {
+		from TaskStatus
+		to   TaskStatus
+		want bool
+	}{
+		{"", TaskQueued, true},
+		{"", TaskReady, true}, // 命中缓存直接就绪
+		{"", TaskSynthesizing, false},
+		{TaskQueued, TaskSynthesizing, true},
+		{TaskSynthesizing, TaskReady, true},
+		{TaskSynthesizing, TaskQueued, true}, // 失败后退避重试
+		{TaskSynthesizing, TaskFailed, true},
+		{TaskReady, TaskSynthesizing, false},
+		{TaskReady, TaskSuperseded, true},
+		{TaskFailed, TaskReady, false},
+		{TaskSuperseded, TaskQueued, true},
+		{TaskCancelled, TaskQueued, false},
+		{TaskCancelled, TaskCancelled, false},
+	}
+
+	for _, c := range cases {
+		if got := c.from.CanTransitionTo(c.to); got != c.want {
+			t.Errorf("%q -> %q: CanTransitionTo = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
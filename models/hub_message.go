@@ -0,0 +1,8 @@
+package models
+
+// HubMessage 多实例广播的中转表：超过 NOTIFY 载荷上限的消息体先存这里，通知里只带 ID
+// 记录只保留很短的时间，由发布方顺手清理
+type HubMessage struct {
+	Base
+	Payload string `gorm:"type:text" json:"payload"`
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 播放记录的状态，对应音箱上报的 PLAY_STARTED / PLAY_FINISHED / PLAY_FAILED
+const (
+	PlayStarted  = "started"
+	PlayFinished = "finished"
+	PlayFailed   = "failed"
+)
+
+// PlayLog 音箱上报的一次真实播放
+// 同一条 HAWKING_NEXT 被同一会话的多个音箱播放时，每个音箱各有一条记录
+type PlayLog struct {
+	Base
+	StoreID   uuid.UUID  `gorm:"type:uuid;not null;index:idx_play_logs_store_date,priority:1" json:"store_id"`
+	PlayDate  string     `gorm:"type:varchar(10);not null;index:idx_play_logs_store_date,priority:2" json:"play_date"` // 开始播放的本地日期 2006-01-02，按天统计用
+	SessionID string     `gorm:"type:varchar(36);index" json:"session_id"`
+	ProductID string     `gorm:"type:varchar(36);index" json:"product_id"`
+	TaskID    string     `gorm:"type:varchar(36);index" json:"task_id"`
+	VoiceType string     `gorm:"type:varchar(50)" json:"voice_type"`
+	AudioURL  string     `gorm:"type:varchar(255)" json:"audio_url"`
+	Seq       int64      `json:"seq"`                                        // 对应 HAWKING_NEXT 的播放序号
+	DeviceID  *uuid.UUID `gorm:"type:uuid;index" json:"device_id,omitempty"` // 上报的设备，店主 App 上报时为空
+	Status    string     `gorm:"type:varchar(20);not null" json:"status"`
+
+	StartedAt  time.Time  `gorm:"not null" json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	DurationMs int64      `json:"duration_ms"` // 实际播放时长，失败时为已经播出的部分
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+}
+
+// PlayReportReq 音箱播放上报，play_id 由音箱为每次播放生成，开始和结束的上报用同一个 play_id 关联
+type PlayReportReq struct {
+	PlayID     string     `json:"play_id" binding:"required,uuid"`
+	StoreID    string     `json:"store_id" binding:"required"`
+	ZoneID     string     `json:"zone_id"`
+	SessionID  string     `json:"session_id"`
+	ProductID  string     `json:"product_id" binding:"required"`
+	TaskID     string     `json:"task_id"`
+	VoiceType  string     `json:"voice_type"`
+	AudioURL   string     `json:"audio_url"`
+	Seq        int64      `json:"seq"`
+	At         *time.Time `json:"at"`          // 事件发生的时间，为空时以服务端收到的时间为准
+	DurationMs int64      `json:"duration_ms"` // PLAY_FINISHED / PLAY_FAILED 时必填
+	Error      string     `json:"error"`       // PLAY_FAILED 的原因
+}
+
+// PlayStat 某天某个商品的播放统计
+type PlayStat struct {
+	Date       string  `json:"date"`
+	ProductID  string  `json:"product_id"`
+	Plays      int64   `json:"plays"`  // 播放完成的次数
+	Failed     int64   `json:"failed"` // 播放失败的次数
+	AirtimeSec float64 `json:"airtime_sec"`
+}
+
+// PlayDayStats 某天的播放汇总
+type PlayDayStats struct {
+	Date       string     `json:"date"`
+	Plays      int64      `json:"plays"`
+	Failed     int64      `json:"failed"`
+	AirtimeSec float64    `json:"airtime_sec"`
+	Products   []PlayStat `json:"products"`
+}
+
+// 播放日期的格式，与 PlayLog.PlayDate 一致
+const PlayDateLayout = "2006-01-02"
+
+// ToPlayLog 把上报转换为播放记录；结束和失败的上报以 at 为结束时间，按播放时长倒推开始时间
+// at 为空或比服务端时间还晚（音箱时钟不准）时以 now 为准
+func (r *PlayReportReq) ToPlayLog(status string, now time.Time) (*PlayLog, error) {
+	playID, err := uuid.Parse(r.PlayID)
+	if err != nil {
+		return nil, fmt.Errorf("play_id 格式不正确")
+	}
+	storeID, err := uuid.Parse(r.StoreID)
+	if err != nil {
+		return nil, fmt.Errorf("store_id 格式不正确")
+	}
+	if r.DurationMs < 0 {
+		return nil, fmt.Errorf("duration_ms 不能为负数")
+	}
+	if status == PlayFinished && r.DurationMs == 0 {
+		return nil, fmt.Errorf("播放完成时必须提供 duration_ms")
+	}
+
+	at := now
+	if r.At != nil && !r.At.IsZero() && r.At.Before(now) {
+		at = *r.At
+	}
+
+	log := &PlayLog{
+		Base:      Base{ID: playID},
+		StoreID:   storeID,
+		SessionID: r.SessionID,
+		ProductID: r.ProductID,
+		TaskID:    r.TaskID,
+		VoiceType: r.VoiceType,
+		AudioURL:  r.AudioURL,
+		Seq:       r.Seq,
+		Status:    status,
+		StartedAt: at,
+		Error:     r.Error,
+	}
+	if status != PlayStarted {
+		log.FinishedAt = &at
+		log.DurationMs = r.DurationMs
+		log.StartedAt = at.Add(-time.Duration(r.DurationMs) * time.Millisecond)
+	}
+	log.PlayDate = log.StartedAt.In(time.Local).Format(PlayDateLayout)
+	return log, nil
+}
+
+// GroupPlayStats 把按天、按商品的统计汇总成每天一项，stats 需按日期排序
+func GroupPlayStats(stats []PlayStat) []PlayDayStats {
+	days := make([]PlayDayStats, 0)
+	for _, stat := range stats {
+		if len(days) == 0 || days[len(days)-1].Date != stat.Date {
+			days = append(days, PlayDayStats{Date: stat.Date, Products: make([]PlayStat, 0)})
+		}
+		day := &days[len(days)-1]
+		day.Plays += stat.Plays
+		day.Failed += stat.Failed
+		day.AirtimeSec += stat.AirtimeSec
+		day.Products = append(day.Products, stat)
+	}
+	return days
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlayReportToPlayLog(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 20, 0, time.Local)
+	earlier := now.Add(-10 * time.Second)
+	later := now.Add(time.Hour)
+	base := PlayReportReq{
+		PlayID:    "5b0c8a8e-6f7e-4a51-9a52-6d6f0e3f6c11",
+		StoreID:   "0f9d3c9a-0c45-4c63-9f1a-2b8f9c0d1e22",
+		ProductID: "p1",
+	}
+
+	cases := []struct {
+		name        string
+		status      string
+		at          *time.Time
+		durationMs  int64
+		wantErr     bool
+		wantStarted time.Time
+		wantDate    string
+	}{
+		{"开始以上报时间为准", PlayStarted, &earlier, 0, false, earlier, "2026-03-01"},
+		{"时钟超前以服务端为准", PlayStarted, &later, 0, false, now, "2026-03-01"},
+		{"完成时倒推开始时间，日期按开始算", PlayFinished, nil, 30000, false, now.Add(-30 * time.Second), "2026-02-28"},
+		{"完成必须带时长", PlayFinished, nil, 0, true, time.Time{}, ""},
+		{"时长不能为负", PlayFailed, nil, -1, true, time.Time{}, ""},
+		{"失败可以没有时长", PlayFailed, nil, 0, false, now, "2026-03-01"},
+	}
+
+	for _, tc := range cases {
+		req := base
+		req.At = tc.at
+		req.DurationMs = tc.durationMs
+		log, err := req.ToPlayLog(tc.status, now)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err=%v, wantErr %v", tc.name, err, tc.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !log.StartedAt.Equal(tc.wantStarted) || log.PlayDate != tc.wantDate {
+			t.Errorf("%s: started=%v date=%s, want %v %s", tc.name, log.StartedAt, log.PlayDate, tc.wantStarted, tc.wantDate)
+		}
+		if (log.FinishedAt != nil) != (tc.status != PlayStarted) {
+			t.Errorf("%s: finished_at=%v", tc.name, log.FinishedAt)
+		}
+	}
+}
+
+func TestGroupPlayStats(t *testing.T) {
+	days := GroupPlayStats([]PlayStat{
+		{Date: "2026-03-01", ProductID: "a", Plays: 3, AirtimeSec: 30},
+		{Date: "2026-03-01", ProductID: "b", Plays: 1, Failed: 2, AirtimeSec: 12.5},
+		{Date: "2026-03-02", ProductID: "a", Plays: 2, AirtimeSec: 20},
+	})
+
+	if len(days) != 2 {
+		t.Fatalf("days=%d, want 2", len(days))
+	}
+	if days[0].Plays != 4 || days[0].Failed != 2 || days[0].AirtimeSec != 42.5 || len(days[0].Products) != 2 {
+		t.Errorf("day 1 = %+v", days[0])
+	}
+	if days[1].Date != "2026-03-02" || days[1].Plays != 2 || len(days[1].Products) != 1 {
+		t.Errorf("day 2 = %+v", days[1])
+	}
+}
@@ -37,7 +37,7 @@ type Product struct {
 
 	// 手动控制的叫卖参数
 	HawkingMode HawkingMode `gorm:"default:0" json:"hawking_mode"`   // 当前模式
-	IsHawking   bool        `gorm:"default:false" json:"is_hawking"` // 是否正在叫卖
+	IsHawking   bool        `gorm:"default:false" json:"is_hawking"` // 是否正在叫卖；队列合成模式下表示有待合成的文案
 	//CustomPrice float64     `json:"custom_price"`                    // 叫卖时的临时价格（比如促销价）
 
 	// 调度元数据
@@ -45,7 +45,7 @@ type Product struct {
 	Priority    int `gorm:"default:0" json:"priority"`      // 优先级：紧急插播使用
 	IntervalSec int `gorm:"default:10" json:"interval_sec"` // 喊完此商品后的停顿时间（秒）
 
-	LastHawkedAt *time.Time `json:"last_hawked_at"` // 上次叫卖完成时间
+	LastHawkedAt *time.Time `json:"last_hawked_at"` // 上次真实播放完成的时间，由音箱的 PLAY_FINISHED 上报更新
 
 	HawkingStatus string    `gorm:"default:'idle'"` // idle, processing
 	LockedAt      time.Time // 用于处理超时锁
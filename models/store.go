@@ -16,6 +16,19 @@ type Store struct {
 	Products []Product `gorm:"foreignKey:StoreID" json:"-"`
 }
 
+// Zone 门店下的播放分区（如肉档、菜档），每个分区有独立的叫卖会话、音色和播放列表
+type Zone struct {
+	Base
+	StoreID   uuid.UUID `gorm:"type:uuid;index;not null" json:"store_id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	VoiceType string    `gorm:"type:varchar(50)" json:"voice_type"` // 分区默认音色
+}
+
+type CreateZoneReq struct {
+	Name      string `json:"name" binding:"required"`
+	VoiceType string `json:"voice_type"`
+}
+
 // SalesRecord 营业额模型
 type SalesRecord struct {
 	Base
@@ -43,6 +56,13 @@ type PromotionSession struct {
 	Items     []MarketingPromotion `gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE" json:"items"`
 }
 
+// Window 促销的有效期：从 StartDate（没有则不限）到 Date 当天结束
+func (p *PromotionSession) Window() TaskWindow {
+	y, m, d := p.Date.In(time.Local).Date()
+	end := time.Date(y, m, d+1, 0, 0, 0, 0, time.Local)
+	return TaskWindow{StartAt: p.StartDate, EndAt: &end}
+}
+
 // MarketingPromotion 具体的特价商品项
 type MarketingPromotion struct {
 	Base
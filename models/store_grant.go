@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 门店授权类型
+const (
+	GrantStaff  = "staff"  // 店员：用自己的账号登录，可以订阅被授权门店的消息
+	GrantDevice = "device" // 设备：音箱用设备令牌连接，只能订阅所属门店（或指定分区）的消息
+)
+
+// StoreGrant 门店授权：店主以外的账号或设备访问门店的凭据，删除即吊销
+type StoreGrant struct {
+	Base
+	StoreID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"store_id"`
+	Kind       string     `gorm:"type:varchar(20);not null" json:"kind"`
+	Name       string     `gorm:"type:varchar(100)" json:"name"`             // 备注名，如「肉档音箱」
+	OwnerID    *uuid.UUID `gorm:"type:uuid;index" json:"owner_id,omitempty"` // 店员的账号，仅 staff
+	ZoneID     *uuid.UUID `gorm:"type:uuid" json:"zone_id,omitempty"`        // 设备限定的分区，为空表示整个门店
+	TokenHash  string     `gorm:"type:varchar(64);index" json:"-"`           // 设备令牌的 SHA-256，仅 device
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`                    // 设备最近一次连接的时间
+}
+
+type CreateGrantReq struct {
+	Kind     string `json:"kind" binding:"required,oneof=staff device"`
+	Username string `json:"username"` // staff：被授权店员的登录名
+	Name     string `json:"name"`
+	ZoneID   string `json:"zone_id"` // device：限定分区，可选
+}
+
+// CreateGrantResp 设备令牌只在创建时返回一次
+type CreateGrantResp struct {
+	StoreGrant
+	Token string `json:"token,omitempty"`
+}
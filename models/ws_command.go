@@ -0,0 +1,42 @@
+package models
+
+import "encoding/json"
+
+// 客户端通过 WebSocket 发送的命令，data 与对应 REST 接口的请求体相同
+const (
+	CmdAddTask     = "ADD_TASK"     // data: AddTaskReq，同 POST /hawking/tasks
+	CmdRemoveTasks = "REMOVE_TASKS" // data: BatchRemoveReq，同 POST /hawking/tasks/batch-remove
+	CmdSwitchVoice = "SWITCH_VOICE" // data: SwitchVoiceReq，同 POST /hawking/switch-voice
+	CmdPause       = "PAUSE"        // data: SessionStateReq，同 POST /hawking/session/pause
+	CmdResume      = "RESUME"       // data: SessionStateReq，同 POST /hawking/session/resume
+	CmdStop        = "STOP"         // data: SessionStateReq，同 POST /hawking/session/stop
+	CmdGetSnapshot = "GET_SNAPSHOT" // data: SessionStateReq，同 GET /hawking/tasks
+
+	// 音箱的播放回执，data: PlayReportReq
+	CmdPlayStarted  = "PLAY_STARTED"
+	CmdPlayFinished = "PLAY_FINISHED"
+	CmdPlayFailed   = "PLAY_FAILED"
+)
+
+// WSCommand 客户端上行的命令：{"id": "c-1", "action": "PAUSE", "data": {"store_id": "..."}}
+// id 由客户端生成，原样带回 ACK/ERROR，用来把回执和请求对应起来
+type WSCommand struct {
+	ID     string          `json:"id"`
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// CommandAckData 命令执行成功：{"type": "ACK", "data": {...}}，result 与 REST 接口的响应体相同
+type CommandAckData struct {
+	ID     string      `json:"id"`
+	Action string      `json:"action"`
+	Result interface{} `json:"result"`
+}
+
+// CommandErrorData 命令执行失败：{"type": "ERROR", "data": {...}}，code 与 REST 接口的 HTTP 状态码一致
+type CommandErrorData struct {
+	ID     string `json:"id,omitempty"`
+	Action string `json:"action,omitempty"`
+	Code   int    `json:"code"`
+	Error  string `json:"error"`
+}
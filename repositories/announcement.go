@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"hawker-backend/models"
+
+	"gorm.io/gorm"
+)
+
+type AnnouncementRepository interface {
+	Create(a *models.Announcement) error
+	Update(a *models.Announcement) error
+	FindByID(id string) (*models.Announcement, error)
+	// FindByStoreID 查询门店最近的广播记录，按时间倒序
+	FindByStoreID(storeID string, limit int) ([]models.Announcement, error)
+}
+
+type announcementRepository struct {
+	db *gorm.DB
+}
+
+func NewAnnouncementRepository(db *gorm.DB) AnnouncementRepository {
+	return &announcementRepository{db: db}
+}
+
+func (r *announcementRepository) Create(a *models.Announcement) error {
+	return r.db.Create(a).Error
+}
+
+func (r *announcementRepository) Update(a *models.Announcement) error {
+	return r.db.Save(a).Error
+}
+
+func (r *announcementRepository) FindByID(id string) (*models.Announcement, error) {
+	var a models.Announcement
+	if err := r.db.First(&a, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *announcementRepository) FindByStoreID(storeID string, limit int) ([]models.Announcement, error) {
+	var list []models.Announcement
+	err := r.db.Where("store_id = ?", storeID).Order("created_at DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
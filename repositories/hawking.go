@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"hawker-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// HawkingSessionRepository 负责叫卖会话与任务的持久化
+type HawkingSessionRepository interface {
+	// SaveSession 整体保存一个 Session：会话信息 Upsert，任务列表整体替换
+	SaveSession(record *models.HawkingSessionRecord) error
+	DeleteSession(sessionID string) error
+	// FindAll 加载所有会话（包含任务），用于服务启动时恢复
+	FindAll() ([]models.HawkingSessionRecord, error)
+	// FindPendingTasks 查询所有会话中该商品还没合成完的任务，供合成队列 worker 使用
+	FindPendingTasks(productID string) ([]models.HawkingTaskRecord, error)
+}
+
+type hawkingSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewHawkingSessionRepository(db *gorm.DB) HawkingSessionRepository {
+	return &hawkingSessionRepository{db: db}
+}
+
+func (r *hawkingSessionRepository) SaveSession(record *models.HawkingSessionRecord) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		tasks := record.Tasks
+		record.Tasks = nil
+		defer func() { record.Tasks = tasks }()
+
+		// 1. 保存会话外壳
+		if err := tx.Save(record).Error; err != nil {
+			return err
+		}
+
+		// 2. 任务数量很少，直接整体替换，避免逐条比对
+		if err := tx.Unscoped().Where("session_id = ?", record.ID).Delete(&models.HawkingTaskRecord{}).Error; err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+		for i := range tasks {
+			tasks[i].SessionID = record.ID
+		}
+		return tx.Create(&tasks).Error
+	})
+}
+
+func (r *hawkingSessionRepository) DeleteSession(sessionID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("session_id = ?", sessionID).Delete(&models.HawkingTaskRecord{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.HawkingSessionRecord{}, "id = ?", sessionID).Error
+	})
+}
+
+func (r *hawkingSessionRepository) FindPendingTasks(productID string) ([]models.HawkingTaskRecord, error) {
+	var records []models.HawkingTaskRecord
+	err := r.db.Where("task_product_id = ? AND task_status IN ?", productID,
+		[]models.TaskStatus{models.TaskQueued, models.TaskSynthesizing}).
+		Order("created_at ASC").Find(&records).Error
+	return records, err
+}
+
+func (r *hawkingSessionRepository) FindAll() ([]models.HawkingSessionRecord, error) {
+	var records []models.HawkingSessionRecord
+	err := r.db.Preload("Tasks", func(db *gorm.DB) *gorm.DB {
+		return db.Order("hawking_task_records.created_at ASC")
+	}).Find(&records).Error
+	return records, err
+}
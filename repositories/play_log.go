@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"errors"
+	"hawker-backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrPlayIDTaken play_id 已经被其他门店的播放记录占用
+var ErrPlayIDTaken = errors.New("play_id 已被其他门店使用")
+
+type PlayLogRepository interface {
+	// Record 写入或更新一条播放记录：开始和结束用同一个 ID，先到的一条负责创建
+	// 只会更新同一门店的记录，ID 属于其他门店时返回 ErrPlayIDTaken
+	// 播放完成时同时更新商品的 LastHawkedAt
+	Record(log *models.PlayLog) error
+	// DailyStats 按天、按商品统计门店的播放次数和时长，日期为闭区间
+	DailyStats(storeID string, from string, to string) ([]models.PlayStat, error)
+}
+
+type playLogRepository struct {
+	db *gorm.DB
+}
+
+func NewPlayLogRepository(db *gorm.DB) PlayLogRepository {
+	return &playLogRepository{db: db}
+}
+
+func (r *playLogRepository) Record(log *models.PlayLog) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// 开始的上报不能覆盖已经到达的结束上报
+		onConflict := clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoNothing: true}
+		if log.Status != models.PlayStarted {
+			onConflict = clause.OnConflict{
+				Columns: []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{
+					"status", "finished_at", "duration_ms", "error", "updated_at",
+				}),
+				// 不能改写其他门店的记录
+				Where: clause.Where{Exprs: []clause.Expression{
+					clause.Expr{SQL: "play_logs.store_id = EXCLUDED.store_id"},
+				}},
+			}
+		}
+		result := tx.Clauses(onConflict).Create(log)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// ID 冲突且没有写入：可能是结束上报先到了，也可能是别的门店的 ID
+			var existing models.PlayLog
+			if err := tx.Select("store_id").First(&existing, "id = ?", log.ID).Error; err != nil {
+				return err
+			}
+			if existing.StoreID != log.StoreID {
+				return ErrPlayIDTaken
+			}
+		}
+
+		if log.Status != models.PlayFinished || log.FinishedAt == nil {
+			return nil
+		}
+		// 多个音箱先后上报时只保留最新的时间
+		return tx.Model(&models.Product{}).
+			Where("id = ? AND store_id = ?", log.ProductID, log.StoreID).
+			Where("last_hawked_at IS NULL OR last_hawked_at < ?", *log.FinishedAt).
+			UpdateColumn("last_hawked_at", *log.FinishedAt).Error
+	})
+}
+
+func (r *playLogRepository) DailyStats(storeID string, from string, to string) ([]models.PlayStat, error) {
+	var stats []models.PlayStat
+	err := r.db.Model(&models.PlayLog{}).
+		Select(`play_date AS date, product_id,
+			COUNT(*) FILTER (WHERE status = ?) AS plays,
+			COUNT(*) FILTER (WHERE status = ?) AS failed,
+			COALESCE(SUM(duration_ms) FILTER (WHERE status = ?), 0) / 1000.0 AS airtime_sec`,
+			models.PlayFinished, models.PlayFailed, models.PlayFinished).
+		Where("store_id = ? AND play_date BETWEEN ? AND ?", storeID, from, to).
+		Group("play_date, product_id").
+		Order("play_date ASC, plays DESC").
+		Scan(&stats).Error
+	return stats, err
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"errors"
+	"hawker-backend/database"
+	"hawker-backend/models"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// 需要真实的 Postgres，连接参数与服务相同（DB_HOST 等环境变量），没有配置时跳过
+func openTestDB(t *testing.T) *gorm.DB {
+	if os.Getenv("DB_HOST") == "" {
+		t.Skip("跳过数据库测试: 没有设置 DB_HOST")
+	}
+	dsn := database.DSN(os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Owner{}, &models.Store{}, &models.Product{}, &models.PlayLog{}, &models.StoreGrant{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	return db
+}
+
+func TestPlayLogRecordRejectsIDFromOtherStore(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewPlayLogRepository(db)
+
+	playID := uuid.New()
+	storeA, storeB := uuid.New(), uuid.New()
+	t.Cleanup(func() { db.Unscoped().Delete(&models.PlayLog{}, "id = ?", playID) })
+
+	now := time.Now()
+	started := &models.PlayLog{
+		Base: models.Base{ID: playID}, StoreID: storeA, PlayDate: now.Format(models.PlayDateLayout),
+		ProductID: uuid.NewString(), Status: models.PlayStarted, StartedAt: now,
+	}
+	if err := repo.Record(started); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	// 另一个门店用同一个 play_id 上报，开始和结束都不能改写原来的记录
+	for _, status := range []string{models.PlayStarted, models.PlayFailed} {
+		other := &models.PlayLog{
+			Base: models.Base{ID: playID}, StoreID: storeB, PlayDate: now.Format(models.PlayDateLayout),
+			ProductID: uuid.NewString(), Status: status, StartedAt: now, FinishedAt: &now, Error: "覆盖",
+		}
+		if err := repo.Record(other); !errors.Is(err, ErrPlayIDTaken) {
+			t.Errorf("%s: 期望 ErrPlayIDTaken，实际 %v", status, err)
+		}
+	}
+
+	var saved models.PlayLog
+	if err := db.First(&saved, "id = ?", playID).Error; err != nil {
+		t.Fatalf("读取记录失败: %v", err)
+	}
+	if saved.StoreID != storeA || saved.Status != models.PlayStarted || saved.Error != "" {
+		t.Errorf("记录被其他门店改写: store=%s status=%s error=%q", saved.StoreID, saved.Status, saved.Error)
+	}
+
+	// 同一门店的结束上报照常更新
+	finished := *started
+	finished.Status = models.PlayFailed
+	finished.FinishedAt = &now
+	if err := repo.Record(&finished); err != nil {
+		t.Fatalf("同门店更新失败: %v", err)
+	}
+}
@@ -20,6 +20,7 @@ type ProductRepository interface {
 	FindDependencies(storeID string, sinceTime *time.Time) ([]models.ProductDependency, error)
 	SyncDependencies(items []models.DependencyDTO) error
 
+	// GetNextHawkingProduct 从合成队列领取一个商品并加租约，队列为空时返回 gorm.ErrRecordNotFound
 	GetNextHawkingProduct() (*models.Product, error)
 	UpdateHawkingStatus(id string, updates map[string]interface{}) error
 }
@@ -89,17 +90,21 @@ func (r *productRepository) GetNextHawkingProduct() (*models.Product, error) {
 		// 1. 直接使用 Clauses 配合锁机制
 		// GORM 会自动将这些条件编译为：
 		// SELECT * FROM products WHERE ... ORDER BY ... LIMIT 1 FOR UPDATE SKIP LOCKED
-		err := tx.Clauses(clause.Locking{
+		result := tx.Clauses(clause.Locking{
 			Strength: "UPDATE",
 			Options:  "SKIP LOCKED",
 		}).Where("is_hawking = ?", true).
 			Where("(hawking_status = ?) OR (hawking_status = ? AND locked_at < ?)",
 				"idle", "processing", lockDeadline).
 			Order("priority DESC, last_hawked_at ASC").
-			First(&product).Error
+			Limit(1).Find(&product)
 
-		if err != nil {
-			return err // 如果没找到会返回 gorm.ErrRecordNotFound，自动回滚
+		if result.Error != nil {
+			return result.Error
+		}
+		// worker 会频繁轮询，队列为空是常态，不用 First 以免每次都打印 record not found
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
 		}
 
 		// 2. 锁定成功后，立即更新状态
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"hawker-backend/models"
+
+	"gorm.io/gorm"
+)
+
+type ProgramRepository interface {
+	Create(p *models.HawkingProgram) error
+	Update(p *models.HawkingProgram) error
+	Delete(id string) error
+	FindByID(id string) (*models.HawkingProgram, error)
+	FindByStoreID(storeID string) ([]models.HawkingProgram, error)
+	// FindEnabled 查询所有门店已启用的节目，供节目调度器使用
+	FindEnabled() ([]models.HawkingProgram, error)
+}
+
+type programRepository struct {
+	db *gorm.DB
+}
+
+func NewProgramRepository(db *gorm.DB) ProgramRepository {
+	return &programRepository{db: db}
+}
+
+func (r *programRepository) Create(p *models.HawkingProgram) error {
+	return r.db.Create(p).Error
+}
+
+func (r *programRepository) Update(p *models.HawkingProgram) error {
+	return r.db.Save(p).Error
+}
+
+func (r *programRepository) Delete(id string) error {
+	return r.db.Delete(&models.HawkingProgram{}, "id = ?", id).Error
+}
+
+func (r *programRepository) FindByID(id string) (*models.HawkingProgram, error) {
+	var p models.HawkingProgram
+	if err := r.db.First(&p, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *programRepository) FindByStoreID(storeID string) ([]models.HawkingProgram, error) {
+	var list []models.HawkingProgram
+	err := r.db.Where("store_id = ?", storeID).Order("daily_start ASC").Find(&list).Error
+	return list, err
+}
+
+func (r *programRepository) FindEnabled() ([]models.HawkingProgram, error) {
+	var list []models.HawkingProgram
+	err := r.db.Where("enabled = ?", true).Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,31 @@
+package repositories
+
+import (
+	"hawker-backend/models"
+
+	"gorm.io/gorm"
+)
+
+type PromotionRepository interface {
+	// FindByID 查询促销场次，Items 按 SortOrder 升序
+	FindByID(id string) (*models.PromotionSession, error)
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) FindByID(id string) (*models.PromotionSession, error) {
+	var session models.PromotionSession
+	err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("marketing_promotions.sort_order ASC")
+	}).First(&session, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
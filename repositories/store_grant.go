@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"errors"
+	"hawker-backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type StoreGrantRepository interface {
+	Create(grant *models.StoreGrant) error
+	FindByStoreID(storeID string) ([]models.StoreGrant, error)
+	Delete(storeID string, grantID string) error
+	// FindDevice 根据设备令牌的哈希找到设备授权，已吊销的找不到
+	FindDevice(tokenHash string) (*models.StoreGrant, error)
+	TouchDevice(grantID uuid.UUID) error
+	FindOwnerIDByUsername(username string) (uuid.UUID, error)
+
+	// IsStoreOwner 是否为门店的店主
+	IsStoreOwner(ownerID uuid.UUID, storeID string) (bool, error)
+	// CanAccessStore 店主或被授权的店员
+	CanAccessStore(ownerID uuid.UUID, storeID string) (bool, error)
+	// ResolveSession 会话 ID 是分区 ID 或门店 ID（默认会话），返回它所属的门店和分区（默认会话没有分区）
+	ResolveSession(sessionID string) (storeID uuid.UUID, zoneID *uuid.UUID, err error)
+}
+
+type storeGrantRepository struct {
+	db *gorm.DB
+}
+
+func NewStoreGrantRepository(db *gorm.DB) StoreGrantRepository {
+	return &storeGrantRepository{db: db}
+}
+
+func (r *storeGrantRepository) Create(grant *models.StoreGrant) error {
+	return r.db.Create(grant).Error
+}
+
+func (r *storeGrantRepository) FindByStoreID(storeID string) ([]models.StoreGrant, error) {
+	var grants []models.StoreGrant
+	err := r.db.Where("store_id = ?", storeID).Order("created_at ASC").Find(&grants).Error
+	return grants, err
+}
+
+func (r *storeGrantRepository) Delete(storeID string, grantID string) error {
+	result := r.db.Where("id = ? AND store_id = ?", grantID, storeID).Delete(&models.StoreGrant{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *storeGrantRepository) FindDevice(tokenHash string) (*models.StoreGrant, error) {
+	var grant models.StoreGrant
+	err := r.db.First(&grant, "token_hash = ? AND kind = ?", tokenHash, models.GrantDevice).Error
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *storeGrantRepository) TouchDevice(grantID uuid.UUID) error {
+	return r.db.Model(&models.StoreGrant{}).Where("id = ?", grantID).
+		UpdateColumn("last_seen_at", time.Now()).Error
+}
+
+func (r *storeGrantRepository) FindOwnerIDByUsername(username string) (uuid.UUID, error) {
+	var owner models.Owner
+	if err := r.db.Select("id").First(&owner, "username = ?", username).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return owner.ID, nil
+}
+
+func (r *storeGrantRepository) IsStoreOwner(ownerID uuid.UUID, storeID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Store{}).Where("id = ? AND owner_id = ?", storeID, ownerID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *storeGrantRepository) CanAccessStore(ownerID uuid.UUID, storeID string) (bool, error) {
+	owner, err := r.IsStoreOwner(ownerID, storeID)
+	if err != nil || owner {
+		return owner, err
+	}
+
+	var count int64
+	err = r.db.Model(&models.StoreGrant{}).
+		Where("store_id = ? AND owner_id = ? AND kind = ?", storeID, ownerID, models.GrantStaff).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *storeGrantRepository) ResolveSession(sessionID string) (uuid.UUID, *uuid.UUID, error) {
+	var zone models.Zone
+	err := r.db.Select("id", "store_id").First(&zone, "id = ?", sessionID).Error
+	if err == nil {
+		return zone.StoreID, &zone.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, nil, err
+	}
+
+	var store models.Store
+	if err := r.db.Select("id").First(&store, "id = ?", sessionID).Error; err != nil {
+		return uuid.Nil, nil, err
+	}
+	return store.ID, nil, nil
+}
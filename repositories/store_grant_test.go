@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"hawker-backend/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCanAccessStore(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewStoreGrantRepository(db)
+
+	owner, staff, device, stranger := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	store := &models.Store{Base: models.Base{ID: uuid.New()}, OwnerID: owner, Name: "测试门店"}
+	otherStore := &models.Store{Base: models.Base{ID: uuid.New()}, OwnerID: stranger, Name: "其他门店"}
+	grants := []*models.StoreGrant{
+		{Base: models.Base{ID: uuid.New()}, StoreID: store.ID, Kind: models.GrantStaff, OwnerID: &staff},
+		// 设备授权即使带了账号也不能当作店员
+		{Base: models.Base{ID: uuid.New()}, StoreID: store.ID, Kind: models.GrantDevice, OwnerID: &device, TokenHash: uuid.NewString()},
+	}
+	for _, id := range []uuid.UUID{owner, stranger} {
+		if err := db.Create(&models.Owner{Base: models.Base{ID: id}, Username: id.String(), Password: "x"}).Error; err != nil {
+			t.Fatalf("创建店主失败: %v", err)
+		}
+	}
+	for _, s := range []*models.Store{store, otherStore} {
+		if err := db.Create(s).Error; err != nil {
+			t.Fatalf("创建门店失败: %v", err)
+		}
+	}
+	for _, g := range grants {
+		if err := repo.Create(g); err != nil {
+			t.Fatalf("创建授权失败: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Delete(&models.StoreGrant{}, "store_id = ?", store.ID)
+		db.Unscoped().Delete(&models.Store{}, "id IN ?", []uuid.UUID{store.ID, otherStore.ID})
+		db.Unscoped().Delete(&models.Owner{}, "id IN ?", []uuid.UUID{owner, stranger})
+	})
+
+	tests := []struct {
+		name    string
+		ownerID uuid.UUID
+		storeID uuid.UUID
+		want    bool
+	}{
+		{"店主", owner, store.ID, true},
+		{"被授权的店员", staff, store.ID, true},
+		{"店员访问其他门店", staff, otherStore.ID, false},
+		{"设备授权的账号", device, store.ID, false},
+		{"无关账号", stranger, store.ID, false},
+	}
+	for _, tt := range tests {
+		got, err := repo.CanAccessStore(tt.ownerID, tt.storeID.String())
+		if err != nil {
+			t.Fatalf("%s: CanAccessStore: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: CanAccessStore = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
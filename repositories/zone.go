@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"hawker-backend/models"
+
+	"gorm.io/gorm"
+)
+
+type ZoneRepository interface {
+	Create(z *models.Zone) error
+	FindByID(id string) (*models.Zone, error)
+	FindByStoreID(storeID string) ([]models.Zone, error)
+}
+
+type zoneRepository struct {
+	db *gorm.DB
+}
+
+func NewZoneRepository(db *gorm.DB) ZoneRepository {
+	return &zoneRepository{db: db}
+}
+
+func (r *zoneRepository) Create(z *models.Zone) error {
+	return r.db.Create(z).Error
+}
+
+func (r *zoneRepository) FindByID(id string) (*models.Zone, error) {
+	var zone models.Zone
+	if err := r.db.First(&zone, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (r *zoneRepository) FindByStoreID(storeID string) ([]models.Zone, error) {
+	var zones []models.Zone
+	err := r.db.Where("store_id = ?", storeID).Order("created_at ASC").Find(&zones).Error
+	return zones, err
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"log"
+	"sync"
+	"time"
+)
+
+// 单条广播最多重复播放的次数，防止误操作刷屏
+const maxAnnouncementRepeat = 20
+
+// AnnouncementEventData HAWKING_ANNOUNCEMENT 消息体：音箱收到后立即中断当前播放，播完再继续轮播
+type AnnouncementEventData struct {
+	AnnouncementID string   `json:"announcement_id"`
+	StoreID        string   `json:"store_id"`
+	ZoneID         string   `json:"zone_id"`
+	SessionIDs     []string `json:"session_ids"` // 受影响的会话
+	Text           string   `json:"text"`
+	AudioURL       string   `json:"audio_url"`
+	VoiceType      string   `json:"voice_type"`
+	Play           int      `json:"play"`   // 第几次播放，从 1 开始
+	Repeat         int      `json:"repeat"` // 总播放次数
+	DurationSec    float64  `json:"duration_sec"`
+	Preempt        bool     `json:"preempt"` // 插播标记：中断当前播放
+}
+
+// Announcer 临时广播服务：合成任意文案，插播到门店（或分区）的正常叫卖之前
+type Announcer struct {
+	repo      repositories.AnnouncementRepository
+	audio     AudioService
+	executor  *SynthesisExecutor
+	scheduler *HawkingScheduler
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // 还在重复播放中的广播，可被取消
+}
+
+func NewAnnouncer(repo repositories.AnnouncementRepository, audio AudioService, executor *SynthesisExecutor, scheduler *HawkingScheduler) *Announcer {
+	return &Announcer{
+		repo:      repo,
+		audio:     audio,
+		executor:  executor,
+		scheduler: scheduler,
+		running:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Announce 合成并立即插播一条广播，合成失败时返回错误（失败记录同样会落库）
+// zoneID 为空表示全店广播；调用方负责校验分区归属
+func (a *Announcer) Announce(announcement *models.Announcement) error {
+	if announcement.Repeat <= 0 {
+		announcement.Repeat = 1
+	}
+	if announcement.Repeat > maxAnnouncementRepeat {
+		announcement.Repeat = maxAnnouncementRepeat
+	}
+
+	sessionID := announcement.StoreID.String()
+	if announcement.ZoneID != "" {
+		sessionID = SessionKey(announcement.ZoneID)
+	}
+	if announcement.VoiceType == "" {
+		announcement.VoiceType = a.scheduler.SessionVoice(sessionID)
+	}
+	if announcement.VoiceType == "" {
+		return fmt.Errorf("当前没有进行中的叫卖会话，请指定广播音色")
+	}
+
+	audioURL, err := a.synthesize(sessionID, announcement.Text, announcement.VoiceType)
+	if err != nil {
+		announcement.Status = models.AnnouncementFailed
+		announcement.Error = err.Error()
+		if createErr := a.repo.Create(announcement); createErr != nil {
+			log.Printf("❌ 广播记录落库失败: %v", createErr)
+		}
+		return fmt.Errorf("广播合成失败: %v", err)
+	}
+
+	announcement.AudioURL = audioURL
+	announcement.Status = models.AnnouncementPlaying
+	if err := a.repo.Create(announcement); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	id := announcement.ID.String()
+	a.mu.Lock()
+	a.running[id] = cancel
+	a.mu.Unlock()
+
+	record := *announcement
+	go a.play(ctx, &record)
+	return nil
+}
+
+// Cancel 停止一条还在重复播放中的广播
+func (a *Announcer) Cancel(id string) bool {
+	a.mu.Lock()
+	cancel, ok := a.running[id]
+	a.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// synthesize 以紧急工作提交给合成执行器，插队到所有叫卖任务之前
+// 相同文案和音色的广播复用已有的音频文件
+func (a *Announcer) synthesize(sessionID string, text string, voiceType string) (string, error) {
+	hash := fmt.Sprintf("%x", md5.Sum([]byte(text)))[:8]
+	fileName := fmt.Sprintf("announce_%s_%s", voiceType, hash)
+	if a.scheduler.checkAudioExists(fileName) {
+		return fmt.Sprintf("/static/audio/%s.mp3", fileName), nil
+	}
+
+	type result struct {
+		url string
+		err error
+	}
+	done := make(chan result, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), a.executor.Policy.Timeout)
+	defer cancel()
+
+	a.executor.Submit(&SynthesisJob{
+		SessionID: sessionID,
+		Urgent:    true,
+		Ctx:       ctx,
+		Run: func(ctx context.Context) {
+			if err := ctx.Err(); err != nil {
+				done <- result{err: err}
+				return
+			}
+			url, err := a.audio.GenerateAudio(ctx, text, fileName, voiceType)
+			done <- result{url: url, err: err}
+		},
+	})
+
+	select {
+	case r := <-done:
+		return r.url, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// play 按重复次数依次插播，每次插播都会暂停对应会话的轮播
+func (a *Announcer) play(ctx context.Context, announcement *models.Announcement) {
+	id := announcement.ID.String()
+	defer func() {
+		a.mu.Lock()
+		delete(a.running, id)
+		a.mu.Unlock()
+	}()
+
+	duration := estimateTextDuration(announcement.Text)
+	interval := duration + time.Duration(announcement.RepeatIntervalSec)*time.Second
+	status := models.AnnouncementDone
+	var rooms []string
+
+	for play := 1; play <= announcement.Repeat; play++ {
+		sessionIDs := a.scheduler.PreemptPlaylists(announcement.StoreID.String(), announcement.ZoneID, duration)
+		// 受影响的各会话房间 + 门店房间（店主的 App 也能看到插播）
+		rooms = []string{StoreRoom(announcement.StoreID.String())}
+		for _, sessionID := range sessionIDs {
+			rooms = append(rooms, SessionRoom(sessionID))
+		}
+		a.scheduler.Hub.BroadcastToRooms(rooms, models.WSMessage{
+			Type: "HAWKING_ANNOUNCEMENT",
+			Data: AnnouncementEventData{
+				AnnouncementID: id,
+				StoreID:        announcement.StoreID.String(),
+				ZoneID:         announcement.ZoneID,
+				SessionIDs:     sessionIDs,
+				Text:           announcement.Text,
+				AudioURL:       announcement.AudioURL,
+				VoiceType:      announcement.VoiceType,
+				Play:           play,
+				Repeat:         announcement.Repeat,
+				DurationSec:    duration.Seconds(),
+				Preempt:        true,
+			},
+		})
+		announcement.PlayedCount = play
+		log.Printf("📢 门店 [%s] 插播广播 (%d/%d): %s", announcement.StoreID, play, announcement.Repeat, announcement.Text)
+
+		if play == announcement.Repeat {
+			break
+		}
+		if !waitOrCancel(ctx, interval) {
+			status = models.AnnouncementCancelled
+			break
+		}
+	}
+
+	now := time.Now()
+	announcement.Status = status
+	announcement.FinishedAt = &now
+	if err := a.repo.Update(announcement); err != nil {
+		log.Printf("❌ 广播记录更新失败 [%s]: %v", id, err)
+	}
+	if status == models.AnnouncementCancelled {
+		a.scheduler.Hub.BroadcastToRooms(rooms, models.WSMessage{Type: "HAWKING_ANNOUNCEMENT_CANCELLED", Data: announcement})
+	}
+}
+
+// waitOrCancel 等待 d 时长，期间 ctx 被取消则返回 false
+func waitOrCancel(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
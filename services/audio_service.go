@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 )
 
 // AudioService 定义语音合成的标准接口
@@ -10,3 +11,10 @@ type AudioService interface {
 	GenerateAudio(ctx context.Context, text string, identifier string, voiceType string) (string, error)
 	GetRealVoiceID(voiceType string) string
 }
+
+// IsPermanentSynthesisError 判断合成错误是否为永久性错误
+// 供应商明确拒绝的请求不再重试；拨号失败、超时、连接中断等都视为临时错误
+func IsPermanentSynthesisError(err error) bool {
+	var providerErr *ProviderError
+	return errors.As(err, &providerErr)
+}
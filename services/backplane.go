@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hawker-backend/models"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// Backplane 多实例部署时 Hub 之间的消息总线
+// 每个实例把本地产生的广播发布到总线，同时把其它实例发布的消息投递给自己的客户端
+type Backplane interface {
+	Publish(msg BackplaneMessage) error
+	// Subscribe 持续接收其它实例发布的消息，直到 ctx 取消；连接断开时由实现自行重连
+	Subscribe(ctx context.Context, handler func(BackplaneMessage))
+}
+
+// BackplaneMessage 总线上传递的一条广播
+type BackplaneMessage struct {
+	Origin string          `json:"origin"` // 发布方实例 ID，收到自己发出的消息时跳过
+	Rooms  []string        `json:"rooms,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Ref    string          `json:"ref,omitempty"` // 消息过大时只传 hub_messages 表里的 ID
+}
+
+const (
+	// NOTIFY 的载荷上限是 8000 字节，留出余量，超出的消息体走 hub_messages 表
+	maxNotifyPayload = 7000
+	// hub_messages 只是中转，保留一小段时间足够所有实例读取
+	hubMessageTTL = time.Minute
+	// 监听连接断开后的重连间隔
+	listenRetry = 3 * time.Second
+)
+
+// PostgresBackplane 基于 Postgres LISTEN/NOTIFY 的总线实现
+// 发布走 GORM 连接池，监听单独占用一条 pgx 连接
+type PostgresBackplane struct {
+	db      *gorm.DB
+	dsn     string
+	channel string
+}
+
+func NewPostgresBackplane(db *gorm.DB, dsn string, channel string) *PostgresBackplane {
+	return &PostgresBackplane{db: db, dsn: dsn, channel: channel}
+}
+
+func (b *PostgresBackplane) Publish(msg BackplaneMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if len(payload) > maxNotifyPayload {
+		// 快照消息带着整个开场白池，经常超过 NOTIFY 的上限，先落表再通知 ID
+		record := models.HubMessage{Payload: string(msg.Data)}
+		if err := b.db.Create(&record).Error; err != nil {
+			return err
+		}
+		b.db.Unscoped().Where("created_at < ?", time.Now().Add(-hubMessageTTL)).Delete(&models.HubMessage{})
+
+		payload, err = json.Marshal(BackplaneMessage{Origin: msg.Origin, Rooms: msg.Rooms, Ref: record.ID.String()})
+		if err != nil {
+			return err
+		}
+	}
+	return b.db.Exec("SELECT pg_notify(?, ?)", b.channel, string(payload)).Error
+}
+
+func (b *PostgresBackplane) Subscribe(ctx context.Context, handler func(BackplaneMessage)) {
+	for {
+		err := b.listen(ctx, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("⚠️ 消息总线监听中断，%v 后重连: %v", listenRetry, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetry):
+		}
+	}
+}
+
+// listen 建立一条监听连接并持续读取通知，连接出错时返回
+func (b *PostgresBackplane) listen(ctx context.Context, handler func(BackplaneMessage)) error {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+	log.Printf("📡 消息总线已连接: %s", b.channel)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var msg BackplaneMessage
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			log.Printf("⚠️ 消息总线收到无法解析的消息: %v", err)
+			continue
+		}
+		if msg.Ref != "" {
+			if err := b.loadRef(&msg); err != nil {
+				log.Printf("⚠️ 消息总线读取大消息失败 [%s]: %v", msg.Ref, err)
+				continue
+			}
+		}
+		handler(msg)
+	}
+}
+
+func (b *PostgresBackplane) loadRef(msg *BackplaneMessage) error {
+	var record models.HubMessage
+	if err := b.db.First(&record, "id = ?", msg.Ref).Error; err != nil {
+		return fmt.Errorf("消息不存在: %v", err)
+	}
+	msg.Data = json.RawMessage(record.Payload)
+	return nil
+}
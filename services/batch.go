@@ -0,0 +1,89 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"log"
+)
+
+// TaskSpec 批量操作中要添加的一项，商品归属由调用方预先校验
+type TaskSpec struct {
+	Product *models.Product
+	Req     models.AddTaskReq
+}
+
+// BatchOp 对一个 Session 的一次批量操作
+//   - 批量添加：Add
+//   - 批量移除：Remove
+//   - 整体替换：ReplaceAll + Add
+//   - 清空会话：ReplaceAll，Add 为空
+type BatchOp struct {
+	StoreID    string
+	ZoneID     string
+	VoiceType  string // Session 不存在时用于创建
+	Add        []TaskSpec
+	Remove     []string // 要移除的任务 ID，传商品 ID 则移除该商品的所有任务
+	ReplaceAll bool     // 先移除 Session 中的所有任务
+}
+
+// ApplyBatch 原子地执行一次批量操作：所有改动在同一把锁内完成，只落库、唤醒、广播各一次
+// 任务全部移除后 Session 会被销毁，与 RemoveTask 一致
+func (s *HawkingScheduler) ApplyBatch(sessionID string, op BatchOp) *models.TasksSnapshotData {
+	sessionID = SessionKey(sessionID)
+	// 文案生成比较慢，先在锁外把任务都构造好
+	tasks := make([]*models.HawkingTask, 0, len(op.Add))
+	for _, spec := range op.Add {
+		tasks = append(tasks, newTask(spec.Product, spec.Req))
+	}
+
+	s.sessionMu.Lock()
+	sess, exists := s.sessions[sessionID]
+	if !exists && len(tasks) == 0 {
+		s.sessionMu.Unlock()
+		return s.GetActiveTasksSnapshot(sessionID)
+	}
+	if !exists {
+		sess = s.getOrStartSessionLocked(sessionID, op.StoreID, op.ZoneID, op.VoiceType)
+	}
+
+	sess.mu.Lock()
+	var events []*TaskStatusEventData
+	removed := 0
+	if op.ReplaceAll {
+		for _, task := range sess.ActiveTasks {
+			events = append(events, s.removeTaskLocked(sess, task, "cleared"))
+			removed++
+		}
+	}
+	for _, ref := range op.Remove {
+		for _, task := range matchTasksLocked(sess, ref) {
+			events = append(events, s.removeTaskLocked(sess, task, "removed"))
+			removed++
+		}
+	}
+	for _, task := range tasks {
+		events = append(events, s.putTaskLocked(sess, task)...)
+	}
+	remaining := len(sess.ActiveTasks)
+	sess.mu.Unlock()
+
+	if remaining == 0 {
+		s.destroySessionLocked(sess)
+	}
+	s.sessionMu.Unlock()
+
+	if remaining > 0 {
+		s.persistSession(sess)
+	}
+	s.emitStatusEvents(events...)
+	if len(tasks) > 0 {
+		sess.notify()
+	}
+	sess.wakePlaylist()
+
+	log.Printf("📦 Session [%s] 批量操作完成: 添加 %d, 移除 %d, 剩余 %d", sessionID, len(tasks), removed, remaining)
+
+	// 一次性下发合并后的任务配置
+	snapshot := s.GetActiveTasksSnapshot(sessionID)
+	s.broadcastSnapshot(snapshot)
+	return snapshot
+}
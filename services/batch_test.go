@@ -0,0 +1,98 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestApplyBatchReplaceAll(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	before := env.waitReady(t, env.addTasks(storeID, "五花肉", "土鸡蛋").SessionID)
+
+	// 整体替换：旧任务全部移除，只剩新加的一个
+	fish := env.addProduct(storeID, "草鱼")
+	snapshot := env.scheduler.ApplyBatch(sessionID, BatchOp{
+		StoreID:    sessionID,
+		ReplaceAll: true,
+		Add:        []TaskSpec{env.spec(storeID, fish)},
+	})
+	if len(snapshot.Products) != 1 || snapshot.Products[0].ProductID != fish.ID.String() {
+		t.Fatalf("替换后的任务 = %+v, want 只有草鱼", snapshot.Products)
+	}
+
+	// 被替换掉的任务都留下删除记录，客户端按增量同步能删干净
+	delta := env.scheduler.GetTasksDelta(sessionID, before.Epoch, before.Revision, "")
+	removed := slices.Clone(delta.Removed)
+	want := taskIDs(before)
+	sort.Strings(removed)
+	sort.Strings(want)
+	if delta.Full || !slices.Equal(removed, want) {
+		t.Errorf("delta full=%v removed=%v, want 增量且删除 %v", delta.Full, removed, want)
+	}
+}
+
+func TestApplyBatchClearDestroysSession(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	env.addTasks(storeID, "五花肉", "土鸡蛋")
+
+	snapshot := env.scheduler.ApplyBatch(sessionID, BatchOp{StoreID: sessionID, ReplaceAll: true})
+	if len(snapshot.Products) != 0 {
+		t.Errorf("清空后还有 %d 个任务", len(snapshot.Products))
+	}
+	if env.scheduler.HasSession(sessionID) {
+		t.Errorf("清空后会话没有销毁")
+	}
+	records, _ := env.sessions.FindAll()
+	if len(records) != 0 {
+		t.Errorf("清空后会话记录没有删除: %d 条", len(records))
+	}
+}
+
+func TestApplyBatchRemoveAndAddTogether(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	before := env.waitReady(t, env.addTasks(storeID, "五花肉", "土鸡蛋").SessionID)
+
+	// 移除最后一个任务的同时加一个新的：会话不能因为中途变空被销毁
+	fish := env.addProduct(storeID, "草鱼")
+	snapshot := env.scheduler.ApplyBatch(sessionID, BatchOp{
+		StoreID: sessionID,
+		Remove:  taskIDs(before),
+		Add:     []TaskSpec{env.spec(storeID, fish)},
+	})
+	if !env.scheduler.HasSession(sessionID) || snapshot.Epoch != before.Epoch {
+		t.Fatalf("同一批里先删后加，会话被重建了")
+	}
+	if len(snapshot.Products) != 1 || snapshot.Products[0].ProductID != fish.ID.String() {
+		t.Errorf("批量操作后的任务 = %+v, want 只有草鱼", snapshot.Products)
+	}
+	if snapshot.Products[0].Status == models.TaskCancelled {
+		t.Errorf("新加的任务被作废了")
+	}
+}
+
+func TestApplyBatchRemovesInOneSave(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	before := env.waitReady(t, env.addTasks(storeID, "五花肉", "土鸡蛋", "草鱼").SessionID)
+	saves := env.sessions.saveCount()
+
+	ids := taskIDs(before)
+	snapshot := env.scheduler.ApplyBatch(sessionID, BatchOp{StoreID: sessionID, Remove: ids[:2]})
+	if got := taskIDs(snapshot); !slices.Equal(got, ids[2:]) {
+		t.Errorf("批量移除后的任务 = %v, want %v", got, ids[2:])
+	}
+	if got := env.sessions.saveCount() - saves; got != 1 {
+		t.Errorf("批量移除落库 %d 次, want 1", got)
+	}
+}
@@ -25,6 +25,16 @@ type DoubaoAudioService struct {
 	StaticDir   string
 }
 
+// ProviderError 火山引擎通过错误帧明确拒绝的请求（参数错误、鉴权失败、文本违规等）
+// 属于永久性错误，原样重试也不会成功
+type ProviderError struct {
+	Message string
+}
+
+func (e *ProviderError) Error() string {
+	return e.Message
+}
+
 // 对应官方的 defaultHeader: version=1, head_size=4, full_request, json, gzip
 var volcHeader = []byte{0x11, 0x10, 0x11, 0x00}
 
@@ -166,7 +176,8 @@ func (s *DoubaoAudioService) processResponse(conn *websocket.Conn, w io.Writer)
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
-			return nil
+			// 正常结束会在收到最后一帧（seq < 0）时返回，走到这里说明连接中途断开，音频不完整
+			return fmt.Errorf("read failed: %v", err)
 		}
 
 		if len(message) < 8 {
@@ -193,11 +204,11 @@ func (s *DoubaoAudioService) processResponse(conn *websocket.Conn, w io.Writer)
 				if startIndex != -1 {
 					decoded, err := s.gzipDecompress(rawPayload[startIndex:])
 					if err == nil {
-						return fmt.Errorf("火山引擎明文报错: %s", string(decoded))
+						return &ProviderError{Message: fmt.Sprintf("火山引擎明文报错: %s", string(decoded))}
 					}
 				}
 			}
-			return fmt.Errorf("无法解压的错误消息(Hex): %X", rawPayload)
+			return &ProviderError{Message: fmt.Sprintf("无法解压的错误消息(Hex): %X", rawPayload)}
 		}
 	}
 }
@@ -8,13 +8,14 @@ import (
 )
 
 func TestDoubaoTTS(t *testing.T) {
-	cfg, err := config.LoadConfig("../config/config.yaml")
+	// 需要真实的火山引擎账号，没有配置文件时跳过
+	cfg, err := conf.LoadConfig("../conf/config.yaml")
 	if err != nil {
-		panic(err)
+		t.Skipf("跳过 TTS 联调: %v", err)
 	}
 
-	svc := NewDoubaoAudioService(cfg.Volcengine.AppID, cfg.Volcengine.AccessToken, cfg.Volcengine.ClusterID, cfg.Volcengine.VoiceType, "./static")
-	url, err := svc.GenerateAudio(context.Background(), "走过路过不要错过，五花肉降价啦，快来买呀！", "test_voice")
+	svc := NewDoubaoAudioService(cfg.Volcengine.AppID, cfg.Volcengine.AccessToken, cfg.Volcengine.ClusterID, "./static")
+	url, err := svc.GenerateAudio(context.Background(), "走过路过不要错过，五花肉降价啦，快来买呀！", "test_voice", cfg.Volcengine.VoiceType)
 	if err != nil {
 		t.Fatalf("API 调通失败: %v", err)
 	}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"hawker-backend/logic"
+	"hawker-backend/models"
+	"log"
+	"time"
+)
+
+// 定时巡检间隔：任务的生效/过期精度以此为准
+const housekeepingInterval = 30 * time.Second
+
+// ScheduleChangeData HAWKING_SCHEDULE_UPDATE 消息体：任务因时间到点而生效、暂停或过期
+type ScheduleChangeData struct {
+	SessionID   string                `json:"session_id"`
+	Activated   []*models.HawkingTask `json:"activated"`   // 进入生效时段
+	Deactivated []*models.HawkingTask `json:"deactivated"` // 离开每日时段（明天还会回来）
+	Expired     []*models.HawkingTask `json:"expired"`     // 已过结束时间，已从 Session 移除
+}
+
+func (c *ScheduleChangeData) empty() bool {
+	return len(c.Activated) == 0 && len(c.Deactivated) == 0 && len(c.Expired) == 0
+}
+
+// RunHousekeeping 调度器的定时巡检，负责所有“到点触发”的逻辑
+func (s *HawkingScheduler) RunHousekeeping() {
+	ticker := time.NewTicker(housekeepingInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		s.tick(now)
+	}
+}
+
+func (s *HawkingScheduler) tick(now time.Time) {
+	s.refreshTaskWindows(now)
+	s.applyMarkdowns(now)
+}
+
+// refreshTaskWindows 根据任务的生效时间自动上线/下线任务，过期任务直接移除
+func (s *HawkingScheduler) refreshTaskWindows(now time.Time) {
+	var changes []*ScheduleChangeData
+	var dirty []*HawkingSession
+	var events []*TaskStatusEventData
+
+	s.sessionMu.Lock()
+	for _, sess := range s.sessions {
+		change := &ScheduleChangeData{SessionID: sess.ID}
+
+		sess.mu.Lock()
+		for _, task := range sess.ActiveTasks {
+			if task.Expired(now) {
+				task.Active = false
+				events = append(events, s.removeTaskLocked(sess, task, "expired"))
+				expired := *task
+				change.Expired = append(change.Expired, &expired)
+				continue
+			}
+
+			active := task.ActiveAt(now)
+			if active == task.Active {
+				continue
+			}
+			task.Active = active
+			sess.touchLocked(task)
+			snapshot := *task
+			if active {
+				change.Activated = append(change.Activated, &snapshot)
+			} else {
+				change.Deactivated = append(change.Deactivated, &snapshot)
+			}
+		}
+		remaining := len(sess.ActiveTasks)
+		sess.mu.Unlock()
+
+		if change.empty() {
+			continue
+		}
+		changes = append(changes, change)
+
+		// 最后一个任务也过期了，和 RemoveTask 一样销毁 Session
+		if remaining == 0 {
+			s.destroySessionLocked(sess)
+			continue
+		}
+		dirty = append(dirty, sess)
+	}
+	s.sessionMu.Unlock()
+
+	for _, sess := range dirty {
+		s.persistSession(sess)
+		sess.wakePlaylist()
+	}
+
+	s.emitStatusEvents(events...)
+	for _, change := range changes {
+		log.Printf("⏰ Session [%s] 任务时段变化: 生效 %d, 暂停 %d, 过期 %d",
+			change.SessionID, len(change.Activated), len(change.Deactivated), len(change.Expired))
+		s.broadcastToSession(change.SessionID, models.WSMessage{Type: "HAWKING_SCHEDULE_UPDATE", Data: change})
+	}
+}
+
+// MarkdownEventData HAWKING_MARKDOWN 消息体：任务到点降价（或次日恢复原价），新音频合成后另有 HAWKING_PLAY_EVENT
+type MarkdownEventData struct {
+	SessionID     string              `json:"session_id"`
+	ProductID     string              `json:"product_id"`
+	TaskID        string              `json:"task_id"`
+	Ratio         float64             `json:"ratio"`
+	Price         float64             `json:"price"`
+	OriginalPrice float64             `json:"original_price"`
+	Product       *models.HawkingTask `json:"product"`
+}
+
+// applyMarkdowns 执行打烊降价：价格变化的任务重新生成文案并重新合成
+func (s *HawkingScheduler) applyMarkdowns(now time.Time) {
+	s.sessionMu.RLock()
+	sessions := make([]*HawkingSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionMu.RUnlock()
+
+	for _, sess := range sessions {
+		// 先找出需要降价的任务，查询商品信息时不持有 Session 锁
+		sess.mu.RLock()
+		due := make(map[string]string) // 任务 ID -> 商品 ID
+		for _, task := range sess.ActiveTasks {
+			if len(task.Markdowns) > 0 && models.MarkdownRatioAt(task.Markdowns, now) != task.CurrentMarkdownRatio() {
+				due[task.ID] = task.ProductID
+			}
+		}
+		sess.mu.RUnlock()
+		if len(due) == 0 {
+			continue
+		}
+
+		var events []*TaskStatusEventData
+		var changes []MarkdownEventData
+		for taskID, productID := range due {
+			product, err := s.productRepo.FindByID(productID)
+			if err != nil {
+				continue
+			}
+
+			sess.mu.Lock()
+			task, ok := sess.ActiveTasks[taskID]
+			if !ok || !task.ApplyMarkdown(now) {
+				sess.mu.Unlock()
+				continue
+			}
+			task.Text = logic.GenerateScript(*product, task)
+			events = append(events, s.requeueTaskLocked(sess, task, "markdown"))
+			snapshot := *task
+			sess.mu.Unlock()
+
+			changes = append(changes, MarkdownEventData{
+				SessionID:     sess.ID,
+				ProductID:     snapshot.ProductID,
+				TaskID:        snapshot.ID,
+				Ratio:         snapshot.MarkdownRatio,
+				Price:         snapshot.Price,
+				OriginalPrice: snapshot.OriginalPrice,
+				Product:       &snapshot,
+			})
+			log.Printf("🏷️ Session [%s] %s 按 %.0f%% 降价，现价 %.1f", sess.ID, product.Name, snapshot.MarkdownRatio*100, snapshot.Price)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		s.persistSession(sess)
+		s.emitStatusEvents(events...)
+		for _, change := range changes {
+			s.broadcastToSession(sess.ID, models.WSMessage{Type: "HAWKING_MARKDOWN", Data: change})
+		}
+		sess.notify()
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// windowTask 带生效时间的添加任务参数
+func (e *testEnv) windowTask(storeID uuid.UUID, name string, window models.TaskWindow) TaskSpec {
+	spec := e.spec(storeID, e.addProduct(storeID, name))
+	spec.Req.TaskWindow = window
+	return spec
+}
+
+func TestRefreshTaskWindows(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	day := func(d, hour int) time.Time {
+		return time.Date(2030, 3, d, hour, 0, 0, 0, time.Local)
+	}
+	endAt := day(1, 21)
+
+	snapshot := env.scheduler.ApplyBatch(sessionID, BatchOp{
+		StoreID:   sessionID,
+		VoiceType: models.VoiceSunnyBoy,
+		Add: []TaskSpec{
+			env.windowTask(storeID, "晚市五花肉", models.TaskWindow{DailyStart: "17:00", DailyEnd: "21:00"}),
+			env.windowTask(storeID, "今晚土鸡蛋", models.TaskWindow{EndAt: &endAt}),
+		},
+	})
+	daily, once := snapshot.Products[0].ID, snapshot.Products[1].ID
+	active := func() map[string]bool {
+		state := make(map[string]bool)
+		for _, task := range env.scheduler.GetActiveTasksSnapshot(sessionID).Products {
+			state[task.ID] = task.Active
+		}
+		return state
+	}
+
+	env.scheduler.refreshTaskWindows(day(1, 18))
+	if state := active(); !state[daily] || !state[once] {
+		t.Fatalf("18:00 两个任务都应生效: %v", state)
+	}
+
+	// 22:00：每日时段结束的任务下线但保留，过了结束时间的任务被移除
+	env.scheduler.refreshTaskWindows(day(1, 22))
+	state := active()
+	if on, ok := state[daily]; !ok || on {
+		t.Errorf("22:00 每日任务应保留并下线: %v", state)
+	}
+	if _, ok := state[once]; ok {
+		t.Errorf("22:00 过期任务没有移除: %v", state)
+	}
+
+	// 第二天 18:00 每日任务重新生效
+	env.scheduler.refreshTaskWindows(day(2, 18))
+	if state := active(); !state[daily] {
+		t.Errorf("第二天 18:00 每日任务没有重新生效: %v", state)
+	}
+}
+
+func TestRefreshTaskWindowsDestroysExpiredSession(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	endAt := time.Now().Add(time.Hour)
+	env.scheduler.ApplyBatch(sessionID, BatchOp{
+		StoreID:   sessionID,
+		VoiceType: models.VoiceSunnyBoy,
+		Add:       []TaskSpec{env.windowTask(storeID, "今晚土鸡蛋", models.TaskWindow{EndAt: &endAt})},
+	})
+
+	// 最后一个任务过期后，和 RemoveTask 一样销毁会话
+	env.scheduler.refreshTaskWindows(endAt.Add(time.Minute))
+	if env.scheduler.HasSession(sessionID) {
+		t.Errorf("最后一个任务过期后会话没有销毁")
+	}
+	if records, _ := env.sessions.FindAll(); len(records) != 0 {
+		t.Errorf("会话记录没有删除: %d 条", len(records))
+	}
+}
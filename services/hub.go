@@ -1,11 +1,18 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"hawker-backend/models"
 	"log"
+	"net"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -14,23 +21,160 @@ type Client struct {
 	Hub  *Hub
 	Conn *websocket.Conn
 	Send chan []byte // 每个客户端独立的待发送消息队列
+
+	// 连接时订阅的房间（见 StoreRoom / SessionRoom），只会收到这些房间的消息
+	Rooms []string
+	// 握手时鉴权得到的身份，订阅的房间都已校验过
+	Identity ClientIdentity
+	replies  chan []byte // 命令的 ACK/ERROR，只回给发命令的这个连接
+}
+
+// ClientIdentity 连接的身份：店主/店员账号，或者门店授权的设备（二者只有一个）
+type ClientIdentity struct {
+	OwnerID  uuid.UUID
+	DeviceID uuid.UUID  // StoreGrant ID
+	StoreID  uuid.UUID  // 设备所属的门店
+	ZoneID   *uuid.UUID // 设备限定的分区，为空表示整个门店
+}
+
+func NewClient(hub *Hub, conn *websocket.Conn, rooms []string, identity ClientIdentity) *Client {
+	return &Client{
+		Hub:      hub,
+		Conn:     conn,
+		Send:     make(chan []byte, 256),
+		Rooms:    rooms,
+		Identity: identity,
+		replies:  make(chan []byte, 16),
+	}
+}
+
+// CommandHandler 处理客户端通过 WebSocket 发来的命令，返回的 ACK/ERROR 只回给该客户端
+// 同一连接的命令按收到的顺序依次处理
+type CommandHandler interface {
+	HandleCommand(client *Client, raw []byte) models.WSMessage
+}
+
+func (id ClientIdentity) String() string {
+	if id.DeviceID != uuid.Nil {
+		return "device:" + id.DeviceID.String()
+	}
+	return "owner:" + id.OwnerID.String()
+}
+
+// StoreRoom 门店房间：门店下所有会话（默认会话和各分区）的消息都会发到这里，适合店主的 App
+func StoreRoom(storeID string) string {
+	return "store:" + strings.ToLower(storeID)
+}
+
+// SessionRoom 会话房间：只接收某一个会话（门店默认会话或某个分区）的消息，适合分区里的音箱
+func SessionRoom(sessionID string) string {
+	return "session:" + strings.ToLower(sessionID)
+}
+
+// Keepalive 连接保活参数，对所有客户端生效
+type Keepalive struct {
+	PingInterval   time.Duration // 服务端发 ping 的间隔，须小于 PongWait
+	PongWait       time.Duration // 这段时间内没收到任何上行数据（包括 pong）即判定为半开连接
+	WriteWait      time.Duration // 单次写超时，避免卡住的连接一直占着写协程
+	MaxMessageSize int64         // 上行消息的大小上限，超出直接断开
+}
+
+// DefaultKeepalive 4G 网络下的音箱经常出现半开连接，一分钟内没有任何响应就断开，让它重连
+func DefaultKeepalive() Keepalive {
+	return Keepalive{
+		PingInterval:   25 * time.Second,
+		PongWait:       60 * time.Second,
+		WriteWait:      10 * time.Second,
+		MaxMessageSize: 64 * 1024,
+	}
 }
 
-// Hub 负责维护所有活跃客户端并处理消息广播
+// hubMessage 待广播的消息，rooms 为空表示全员广播
+// 同一条消息发往多个房间时，同时在这些房间里的客户端只会收到一次
+type hubMessage struct {
+	rooms []string
+	data  []byte
+}
+
+// Hub 负责维护所有活跃客户端，按房间分发消息
 type Hub struct {
 	Clients    map[*Client]bool
-	broadcast  chan []byte  // 待广播的消息管道
-	Register   chan *Client // 注册请求管道
-	Unregister chan *Client // 注销请求管道
+	rooms      map[string]map[*Client]bool // 房间 -> 房间内的客户端，只在 Run 协程中读写
+	broadcast  chan hubMessage             // 待广播的消息管道
+	Register   chan *Client                // 注册请求管道
+	Unregister chan *Client                // 注销请求管道
 	mu         sync.Mutex
+	keepalive  Keepalive
+	commands   CommandHandler // 为空时忽略客户端发来的消息
+
+	// 多实例部署时的消息总线，为空表示单实例，只推送给本地客户端
+	instanceID string
+	backplane  Backplane
+	outbox     chan BackplaneMessage // 按产生顺序依次发布，保证 HAWKING_NEXT 等消息不乱序
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan hubMessage),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		Clients:    make(map[*Client]bool),
+		rooms:      make(map[string]map[*Client]bool),
+		instanceID: uuid.NewString(),
+		keepalive:  DefaultKeepalive(),
+	}
+}
+
+// SetCommandHandler 接收客户端命令；须在接受连接之前调用
+func (h *Hub) SetCommandHandler(handler CommandHandler) {
+	h.commands = handler
+}
+
+// SetKeepalive 修改连接保活参数，非法的值沿用默认值；须在接受连接之前调用
+func (h *Hub) SetKeepalive(k Keepalive) {
+	d := DefaultKeepalive()
+	if k.PongWait <= 0 {
+		k.PongWait = d.PongWait
+	}
+	if k.PingInterval <= 0 || k.PingInterval >= k.PongWait {
+		k.PingInterval = k.PongWait * 9 / 10
+		log.Printf("⚠️ WebSocket ping 间隔必须小于 pong 超时，已调整为 %v", k.PingInterval)
+	}
+	if k.WriteWait <= 0 {
+		k.WriteWait = d.WriteWait
+	}
+	if k.MaxMessageSize <= 0 {
+		k.MaxMessageSize = d.MaxMessageSize
+	}
+	h.keepalive = k
+}
+
+// UseBackplane 接入消息总线：本实例的广播同时发布给其它实例，其它实例的广播投递给本地客户端
+// 须在开始广播之前调用
+func (h *Hub) UseBackplane(ctx context.Context, backplane Backplane) {
+	h.backplane = backplane
+	h.outbox = make(chan BackplaneMessage, 256)
+
+	go func() {
+		for msg := range h.outbox {
+			if err := backplane.Publish(msg); err != nil {
+				log.Printf("❌ 消息总线发布失败: %v", err)
+			}
+		}
+	}()
+	go backplane.Subscribe(ctx, func(msg BackplaneMessage) {
+		if msg.Origin == h.instanceID {
+			return // 自己发出的消息已经推送过本地客户端
+		}
+		h.broadcast <- hubMessage{rooms: msg.Rooms, data: msg.Data}
+	})
+}
+
+// publish 推送给本地客户端，并转发给其它实例
+func (h *Hub) publish(message hubMessage) {
+	h.broadcast <- message
+	if h.backplane != nil {
+		h.outbox <- BackplaneMessage{Origin: h.instanceID, Rooms: message.rooms, Data: message.data}
 	}
 }
 
@@ -39,69 +183,170 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.Register:
 			h.Clients[client] = true
-			log.Println("📱 新客户端已连接")
-		case client := <-h.Unregister:
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				close(client.Send)
-				log.Println("👋 客户端已断开")
+			for _, room := range client.Rooms {
+				if h.rooms[room] == nil {
+					h.rooms[room] = make(map[*Client]bool)
+				}
+				h.rooms[room][client] = true
+			}
+			if len(client.Rooms) == 0 {
+				log.Println("⚠️ 客户端未订阅任何门店或会话，将收不到叫卖消息")
 			}
+			log.Printf("📱 新客户端已连接 [%s]，订阅: %v", client.Identity, client.Rooms)
+		case client := <-h.Unregister:
+			h.removeClient(client)
 		case message := <-h.broadcast:
-			// 异步分发给所有客户端，不阻塞广播管道
-			for client := range h.Clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.Clients, client)
-				}
+			h.deliver(message)
+		}
+	}
+}
+
+// deliver 把消息投递给目标房间里的客户端，每个客户端最多一次
+func (h *Hub) deliver(message hubMessage) {
+	targets := h.Clients
+	if len(message.rooms) > 0 {
+		targets = make(map[*Client]bool)
+		for _, room := range message.rooms {
+			for client := range h.rooms[room] {
+				targets[client] = true
 			}
 		}
 	}
+
+	// 异步分发给客户端，不阻塞广播管道
+	for client := range targets {
+		select {
+		case client.Send <- message.data:
+		default:
+			h.removeClient(client)
+		}
+	}
+}
+
+// removeClient 注销客户端并关闭它的发送队列，重复调用是安全的
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.Clients[client]; !ok {
+		return
+	}
+	delete(h.Clients, client)
+	for _, room := range client.Rooms {
+		delete(h.rooms[room], client)
+		if len(h.rooms[room]) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+	close(client.Send)
+	log.Println("👋 客户端已断开")
 }
 
+// Broadcast 推送给所有客户端，只用于与门店无关的系统消息
 func (h *Hub) Broadcast(payload models.WSMessage) {
 	message, _ := json.Marshal(payload)
-	h.broadcast <- message
+	h.publish(hubMessage{data: message})
 }
 
-func (h *Hub) BroadcastTaskBundle(data *models.TasksSnapshotData) {
+// BroadcastToRooms 推送给这些房间里的客户端
+func (h *Hub) BroadcastToRooms(rooms []string, payload models.WSMessage) {
+	message, _ := json.Marshal(payload)
+	h.publish(hubMessage{rooms: rooms, data: message})
+}
+
+// BroadcastTaskBundle 推送任务快照
+func (h *Hub) BroadcastTaskBundle(rooms []string, data *models.TasksSnapshotData) {
 	bundle := models.TaskBundle{
 		Type: "TASK_CONF_UPDATE",
 		Data: data,
 	}
 	payload, _ := json.Marshal(bundle)
-	h.broadcast <- payload
+	h.publish(hubMessage{rooms: rooms, data: payload})
 }
 
 // --- Client 相关方法 ---
 
+// ReadPump 读取上行数据直到连接断开；读超时（心跳丢失）、消息过大或对方关闭都会走到注销
 func (c *Client) ReadPump() {
+	keepalive := c.Hub.keepalive
 	defer func() {
 		c.Hub.Unregister <- c
 		c.Conn.Close()
 	}()
-	// 此处主要用于监听心跳或客户端主动关闭信号
+
+	c.Conn.SetReadLimit(keepalive.MaxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(keepalive.PongWait))
+	// 收到 pong 说明连接还活着，顺延读超时
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(keepalive.PongWait))
+	})
+
 	for {
-		_, _, err := c.Conn.ReadMessage()
+		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
+			var netErr net.Error
+			switch {
+			case errors.As(err, &netErr) && netErr.Timeout():
+				log.Printf("💤 客户端心跳超时 [%s]", c.Identity)
+			case errors.Is(err, websocket.ErrReadLimit):
+				log.Printf("⚠️ 客户端消息超过 %d 字节，断开连接 [%s]", keepalive.MaxMessageSize, c.Identity)
+			case websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived):
+				log.Printf("⚠️ 客户端连接异常断开 [%s]: %v", c.Identity, err)
+			}
 			break
 		}
+		// 任何上行数据都说明连接正常
+		c.Conn.SetReadDeadline(time.Now().Add(keepalive.PongWait))
+		c.handleMessage(message)
 	}
 }
 
+// handleMessage 处理一条上行消息；旧版客户端会发送纯文本心跳，不是 JSON 对象的消息直接忽略
+func (c *Client) handleMessage(message []byte) {
+	message = bytes.TrimSpace(message)
+	if c.Hub.commands == nil || len(message) == 0 || message[0] != '{' {
+		return
+	}
+
+	reply, _ := json.Marshal(c.Hub.commands.HandleCommand(c, message))
+	select {
+	case c.replies <- reply:
+	default:
+		// 写协程已退出或者客户端一直不收，连接马上会被断开
+		log.Printf("⚠️ 命令回执队列已满，丢弃 [%s]", c.Identity)
+	}
+}
+
+// WritePump 发送下行消息并定时 ping；任何一次写超时或失败都关闭连接，由 ReadPump 负责注销
 func (c *Client) WritePump() {
+	keepalive := c.Hub.keepalive
+	ticker := time.NewTicker(keepalive.PingInterval)
 	defer func() {
+		ticker.Stop()
 		c.Conn.Close()
 	}()
+
 	for {
 		select {
 		case message, ok := <-c.Send:
 			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				// Hub 已注销该客户端（比如发送队列堆满），礼貌地关闭
+				c.Conn.WriteControl(websocket.CloseMessage, []byte{}, time.Now().Add(keepalive.WriteWait))
+				return
+			}
+			c.Conn.SetWriteDeadline(time.Now().Add(keepalive.WriteWait))
+			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("⚠️ 消息发送失败，断开连接 [%s]: %v", c.Identity, err)
+				return
+			}
+		case reply := <-c.replies:
+			c.Conn.SetWriteDeadline(time.Now().Add(keepalive.WriteWait))
+			if err := c.Conn.WriteMessage(websocket.TextMessage, reply); err != nil {
+				log.Printf("⚠️ 命令回执发送失败，断开连接 [%s]: %v", c.Identity, err)
+				return
+			}
+		case <-ticker.C:
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(keepalive.WriteWait)); err != nil {
+				log.Printf("💤 ping 发送失败，断开连接 [%s]: %v", c.Identity, err)
 				return
 			}
-			c.Conn.WriteMessage(websocket.TextMessage, message)
 		}
 	}
 }
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialHub 起一个只挂了 Hub 的 WebSocket 服务并连上去
+func dialHub(t *testing.T, hub *Hub) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(hub, conn, nil, ClientIdentity{})
+		hub.Register <- client
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntilClosed 一直读到连接被服务端断开，返回是否在 timeout 内断开
+func readUntilClosed(conn *websocket.Conn, timeout time.Duration) bool {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var netErr net.Error
+			return !(errors.As(err, &netErr) && netErr.Timeout())
+		}
+	}
+}
+
+func newKeepaliveHub() *Hub {
+	hub := NewHub()
+	hub.SetKeepalive(Keepalive{
+		PingInterval:   50 * time.Millisecond,
+		PongWait:       200 * time.Millisecond,
+		WriteWait:      100 * time.Millisecond,
+		MaxMessageSize: 64,
+	})
+	go hub.Run()
+	return hub
+}
+
+func TestHubReapsHalfOpenConnection(t *testing.T) {
+	conn := dialHub(t, newKeepaliveHub())
+	// 模拟半开连接：收到 ping 不回 pong
+	conn.SetPingHandler(func(string) error { return nil })
+	if !readUntilClosed(conn, 2*time.Second) {
+		t.Errorf("不回 pong 的连接没有被断开")
+	}
+}
+
+func TestHubKeepsLiveConnection(t *testing.T) {
+	conn := dialHub(t, newKeepaliveHub())
+	// 默认的 ping 处理会回 pong，超过 PongWait 后连接依然保持
+	if readUntilClosed(conn, 600*time.Millisecond) {
+		t.Errorf("正常回 pong 的连接被断开了")
+	}
+}
+
+func TestHubDropsOversizedMessage(t *testing.T) {
+	conn := dialHub(t, newKeepaliveHub())
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("x", 65))); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if !readUntilClosed(conn, time.Second) {
+		t.Errorf("超过大小上限的消息没有导致断开")
+	}
+}
+
+func TestSetKeepaliveFixesInvalidValues(t *testing.T) {
+	hub := NewHub()
+	hub.SetKeepalive(Keepalive{PingInterval: time.Minute, PongWait: 10 * time.Second})
+	k := hub.keepalive
+	if k.PingInterval != 9*time.Second {
+		t.Errorf("PingInterval = %v, want 9s（须小于 PongWait）", k.PingInterval)
+	}
+	d := DefaultKeepalive()
+	if k.WriteWait != d.WriteWait || k.MaxMessageSize != d.MaxMessageSize {
+		t.Errorf("未设置的参数没有沿用默认值: %+v", k)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestHubDeliversToRooms(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	storeA, storeB, zoneA := uuid.NewString(), uuid.NewString(), uuid.NewString()
+
+	app := subscribe(hub, StoreRoom(storeA), SessionRoom(zoneA)) // 同时在门店和分区房间
+	speaker := subscribe(hub, SessionRoom(zoneA))
+	otherStore := subscribe(hub, StoreRoom(storeB))
+
+	// 分区会话的消息同时发往会话房间和门店房间，同在两个房间的客户端只收到一次
+	hub.BroadcastToRooms([]string{SessionRoom(zoneA), StoreRoom(storeA)}, models.WSMessage{Type: "HAWKING_NEXT"})
+	for name, client := range map[string]*Client{"app": app, "speaker": speaker} {
+		if waitMessage(client, "HAWKING_NEXT", time.Second) == nil {
+			t.Errorf("%s 没有收到分区的消息", name)
+		}
+	}
+	if waitMessage(app, "HAWKING_NEXT", 100*time.Millisecond) != nil {
+		t.Errorf("app 重复收到了同一条消息")
+	}
+	if waitMessage(otherStore, "HAWKING_NEXT", 100*time.Millisecond) != nil {
+		t.Errorf("其他门店收到了该门店的消息")
+	}
+
+	// 系统消息推送给所有客户端
+	hub.Broadcast(models.WSMessage{Type: "SYSTEM"})
+	for name, client := range map[string]*Client{"app": app, "speaker": speaker, "otherStore": otherStore} {
+		if waitMessage(client, "SYSTEM", time.Second) == nil {
+			t.Errorf("%s 没有收到系统消息", name)
+		}
+	}
+
+	// 断开后不再投递，发送队列被关闭
+	hub.Unregister <- speaker
+	hub.BroadcastToRooms([]string{SessionRoom(zoneA)}, models.WSMessage{Type: "HAWKING_NEXT"})
+	if waitMessage(app, "HAWKING_NEXT", time.Second) == nil {
+		t.Errorf("有客户端断开后，同房间的其他客户端收不到消息")
+	}
+	if _, ok := <-speaker.Send; ok {
+		t.Errorf("断开的客户端仍收到了消息")
+	}
+}
+
+func TestSessionRoomsIncludeStore(t *testing.T) {
+	env := newTestEnv(t)
+	storeID, zoneID := uuid.New(), uuid.New()
+	pork := env.addProduct(storeID, "五花肉")
+	env.scheduler.ApplyBatch(zoneID.String(), BatchOp{
+		StoreID:   storeID.String(),
+		ZoneID:    zoneID.String(),
+		VoiceType: models.VoiceSunnyBoy,
+		Add:       []TaskSpec{env.spec(storeID, pork)},
+	})
+
+	// 分区会话的消息也要发到门店房间，店主的 App 才能看到
+	rooms := env.scheduler.sessionRooms(zoneID.String())
+	want := []string{SessionRoom(zoneID.String()), StoreRoom(storeID.String())}
+	if len(rooms) != 2 || rooms[0] != want[0] || rooms[1] != want[1] {
+		t.Errorf("sessionRooms = %v, want %v", rooms, want)
+	}
+	// 门店默认会话的 ID 就是门店 ID
+	if rooms := env.scheduler.sessionRooms(storeID.String()); rooms[1] != StoreRoom(storeID.String()) {
+		t.Errorf("门店默认会话的房间 = %v", rooms)
+	}
+}
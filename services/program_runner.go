@@ -0,0 +1,199 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgramRunner 节目调度器：按每周时段把节目加载进门店（分区）的叫卖会话
+// 加载出来的任务带有本场的结束时间，到点后由定时巡检自动移除，无需单独卸载
+type ProgramRunner struct {
+	repo        repositories.ProgramRepository
+	productRepo repositories.ProductRepository
+	scheduler   *HawkingScheduler
+
+	mu     sync.Mutex
+	loaded map[string]time.Time // 节目 -> 已加载场次的开始时间，同一场只加载一次
+}
+
+func NewProgramRunner(repo repositories.ProgramRepository, productRepo repositories.ProductRepository, scheduler *HawkingScheduler) *ProgramRunner {
+	return &ProgramRunner{
+		repo:        repo,
+		productRepo: productRepo,
+		scheduler:   scheduler,
+		loaded:      make(map[string]time.Time),
+	}
+}
+
+// Run 启动时先同步一次，之后跟随巡检间隔定时同步
+func (r *ProgramRunner) Run() {
+	r.Sync(time.Now())
+
+	ticker := time.NewTicker(housekeepingInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		r.Sync(now)
+	}
+}
+
+// Sync 加载所有到点的节目
+func (r *ProgramRunner) Sync(now time.Time) {
+	programs, err := r.repo.FindEnabled()
+	if err != nil {
+		log.Printf("❌ 查询叫卖节目失败: %v", err)
+		return
+	}
+
+	// 服务重启后内存记录丢失，以会话里还留着的节目任务为准，避免重复加载
+	running := r.scheduler.LoadedPrograms()
+
+	for i := range programs {
+		program := &programs[i]
+		start, end, ok := program.OccurrenceAt(now)
+		if !ok {
+			continue
+		}
+
+		id := program.ID.String()
+		r.mu.Lock()
+		loadedStart, seen := r.loaded[id]
+		if !seen && running[id] {
+			r.loaded[id] = start
+			loadedStart, seen = start, true
+		}
+		r.mu.Unlock()
+		// 本场已经加载过（即使店主中途手动移除了任务，也不再重复加载）
+		if seen && loadedStart.Equal(start) {
+			continue
+		}
+
+		r.load(program, start, end)
+	}
+}
+
+// Reload 节目被修改后调用：先卸载已加载的任务，到点的话按新配置重新加载
+func (r *ProgramRunner) Reload(program *models.HawkingProgram) {
+	r.Unload(program.ID.String())
+	if !program.Enabled {
+		return
+	}
+	if start, end, ok := program.OccurrenceAt(time.Now()); ok {
+		r.load(program, start, end)
+	}
+}
+
+// Unload 从会话中移除该节目加载的所有任务
+func (r *ProgramRunner) Unload(programID string) {
+	r.mu.Lock()
+	delete(r.loaded, programID)
+	r.mu.Unlock()
+
+	if removed := r.scheduler.UnloadProgram(programID); removed > 0 {
+		log.Printf("📴 节目 [%s] 已卸载 %d 个任务", programID, removed)
+	}
+}
+
+// load 把节目的任务逐个加入会话，有效期为本场的起止时间
+func (r *ProgramRunner) load(program *models.HawkingProgram, start time.Time, end time.Time) {
+	id := program.ID.String()
+	storeID := program.StoreID.String()
+	sessionID := storeID
+	if program.ZoneID != "" {
+		sessionID = program.ZoneID
+	}
+
+	op := BatchOp{StoreID: storeID, ZoneID: program.ZoneID, VoiceType: program.VoiceType}
+	for _, item := range program.Items {
+		product, err := r.productRepo.FindByID(item.ProductID)
+		if err != nil || product.StoreID != program.StoreID {
+			log.Printf("⚠️ 节目 [%s] 跳过无效商品: %s", program.Name, item.ProductID)
+			continue
+		}
+
+		voiceType := item.VoiceType
+		if voiceType == "" {
+			voiceType = program.VoiceType
+		}
+		unit := item.Unit
+		if unit == "" {
+			unit = product.Unit
+		}
+		startAt, endAt := start, end
+
+		op.Add = append(op.Add, TaskSpec{Product: product, Req: models.AddTaskReq{
+			StoreID:       storeID,
+			ZoneID:        program.ZoneID,
+			ProductID:     product.ID.String(),
+			Text:          item.Text,
+			Price:         item.Price,
+			OriginalPrice: item.OriginalPrice,
+			Unit:          unit,
+			MinQty:        item.MinQty,
+			ConditionUnit: item.ConditionUnit,
+			PromotionTag:  item.PromotionTag,
+			VoiceType:     voiceType,
+			PinVoice:      voiceType != "", // 节目指定了音色就固定下来，不受会话音色切换影响
+			UseRepeatMode: program.UseRepeatMode,
+			Weight:        item.Weight,
+			Priority:      item.Priority,
+			IntervalSec:   item.IntervalSec,
+			TaskWindow:    models.TaskWindow{StartAt: &startAt, EndAt: &endAt},
+			SortOrder:     item.SortOrder,
+			ProgramID:     id,
+		}})
+	}
+	if len(op.Add) > 0 {
+		r.scheduler.ApplyBatch(sessionID, op)
+	}
+
+	r.mu.Lock()
+	r.loaded[id] = start
+	r.mu.Unlock()
+	log.Printf("📺 节目 [%s] 已加载到 Session [%s]: %d 个任务，%s 结束", program.Name, sessionID, len(op.Add), end.Format(models.ClockLayout))
+}
+
+// LoadedPrograms 返回当前会话中还有任务在播的节目
+func (s *HawkingScheduler) LoadedPrograms() map[string]bool {
+	programs := make(map[string]bool)
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	for _, sess := range s.sessions {
+		sess.mu.RLock()
+		for _, task := range sess.ActiveTasks {
+			if task.ProgramID != "" {
+				programs[task.ProgramID] = true
+			}
+		}
+		sess.mu.RUnlock()
+	}
+	return programs
+}
+
+// UnloadProgram 移除所有会话中来自该节目的任务，返回移除数量
+// 每个会话的任务在一次批量操作里移除，客户端只会收到一次快照
+func (s *HawkingScheduler) UnloadProgram(programID string) int {
+	taskIDs := make(map[string][]string)
+	total := 0
+
+	s.sessionMu.RLock()
+	for _, sess := range s.sessions {
+		sess.mu.RLock()
+		for _, task := range sess.ActiveTasks {
+			if strings.EqualFold(task.ProgramID, programID) {
+				taskIDs[sess.ID] = append(taskIDs[sess.ID], task.ID)
+				total++
+			}
+		}
+		sess.mu.RUnlock()
+	}
+	s.sessionMu.RUnlock()
+
+	for sessionID, ids := range taskIDs {
+		s.ApplyBatch(sessionID, BatchOp{Remove: ids})
+	}
+	return total
+}
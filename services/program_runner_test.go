@@ -0,0 +1,89 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestProgramLoadUppercaseZoneSharesSession(t *testing.T) {
+	env := newTestEnv(t)
+	storeID, zoneID := uuid.New(), uuid.New()
+	pork := env.addProduct(storeID, "五花肉")
+	egg := env.addProduct(storeID, "土鸡蛋")
+
+	// iOS 端传来的分区 ID 是大写的
+	program := &models.HawkingProgram{
+		Base:    models.Base{ID: uuid.New()},
+		StoreID: storeID,
+		ZoneID:  strings.ToUpper(zoneID.String()),
+		Name:    "早市",
+		Items:   []models.ProgramItem{{ProductID: pork.ID.String(), Text: "五花肉十二块一斤", Price: 12}},
+	}
+	runner := NewProgramRunner(nil, env.products, env.scheduler)
+	now := time.Now()
+	runner.load(program, now, now.Add(time.Hour))
+
+	// 通过 REST 接口（resolveSession 返回数据库里的小写 ID）往同一个分区加任务
+	env.scheduler.ApplyBatch(zoneID.String(), BatchOp{
+		StoreID: storeID.String(),
+		ZoneID:  zoneID.String(),
+		Add: []TaskSpec{{Product: egg, Req: models.AddTaskReq{
+			StoreID: storeID.String(), ZoneID: zoneID.String(), ProductID: egg.ID.String(), Text: "土鸡蛋一块五一个", Price: 1.5,
+		}}},
+	})
+
+	if n := env.sessionCount(); n != 1 {
+		t.Fatalf("sessions=%d, want 1（大小写不同的分区 ID 不能各起一个会话）", n)
+	}
+	snapshot := env.scheduler.GetActiveTasksSnapshot(strings.ToUpper(zoneID.String()))
+	if snapshot.SessionID != zoneID.String() || len(snapshot.Products) != 2 {
+		t.Errorf("snapshot session=%s tasks=%d, want %s 2", snapshot.SessionID, len(snapshot.Products), zoneID)
+	}
+}
+
+func TestUnloadProgramRemovesTasksInOneBatch(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	pork := env.addProduct(storeID, "五花肉")
+	egg := env.addProduct(storeID, "土鸡蛋")
+	fish := env.addProduct(storeID, "鲈鱼")
+
+	program := &models.HawkingProgram{
+		Base:    models.Base{ID: uuid.New()},
+		StoreID: storeID,
+		Name:    "早市",
+		Items: []models.ProgramItem{
+			{ProductID: pork.ID.String(), Text: "五花肉十二块一斤", Price: 12},
+			{ProductID: egg.ID.String(), Text: "土鸡蛋一块五一个", Price: 1.5},
+		},
+	}
+	runner := NewProgramRunner(nil, env.products, env.scheduler)
+	now := time.Now()
+	runner.load(program, now, now.Add(time.Hour))
+
+	// 手动添加的任务不属于节目，卸载时要保留
+	env.scheduler.ApplyBatch(storeID.String(), BatchOp{
+		StoreID: storeID.String(),
+		Add: []TaskSpec{{Product: fish, Req: models.AddTaskReq{
+			StoreID: storeID.String(), ProductID: fish.ID.String(), Text: "鲈鱼二十块一条", Price: 20,
+		}}},
+	})
+
+	// 等合成完再计数，合成结果落库不算在卸载里
+	env.waitReady(t, storeID.String())
+	saves := env.sessions.saveCount()
+	if n := env.scheduler.UnloadProgram(program.ID.String()); n != 2 {
+		t.Fatalf("UnloadProgram = %d, want 2", n)
+	}
+	if n := env.sessions.saveCount() - saves; n != 1 {
+		t.Errorf("卸载落库 %d 次，want 1（同一会话的任务要在一次批量操作里移除）", n)
+	}
+	snapshot := env.scheduler.GetActiveTasksSnapshot(storeID.String())
+	if len(snapshot.Products) != 1 || snapshot.Products[0].ProductID != fish.ID.String() {
+		t.Errorf("卸载后剩余 %d 个任务，want 只剩鲈鱼", len(snapshot.Products))
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶限流器：以 rate 个/秒 的速度补充令牌，最多积攒 burst 个
+type TokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:     rate,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Wait 阻塞直到拿到一个令牌，或 ctx 被取消
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// RateLimitedAudioService 给任意 AudioService 加上请求限流
+// 只有真正打到供应商的请求才消耗令牌，命中本地缓存的合成不受影响
+type RateLimitedAudioService struct {
+	AudioService
+	limiter *TokenBucket
+}
+
+// NewRateLimitedAudioService rate <= 0 表示不限流，直接返回原服务
+func NewRateLimitedAudioService(inner AudioService, rate float64, burst int) AudioService {
+	if rate <= 0 {
+		return inner
+	}
+	return &RateLimitedAudioService{AudioService: inner, limiter: NewTokenBucket(rate, burst)}
+}
+
+func (s *RateLimitedAudioService) GenerateAudio(ctx context.Context, text string, identifier string, voiceType string) (string, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	return s.AudioService.GenerateAudio(ctx, text, identifier, voiceType)
+}
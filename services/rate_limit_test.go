@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketWait(t *testing.T) {
+	b := NewTokenBucket(20, 2)
+	ctx := context.Background()
+
+	// 积攒的令牌可以立即用掉
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("突发请求等待了 %v", elapsed)
+	}
+
+	// 之后按 20 个/秒 补充，大约 50ms 一个
+	start = time.Now()
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("令牌用完后只等待了 %v", elapsed)
+	}
+
+	// 等待期间取消
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.Wait(cancelled); err == nil {
+		t.Errorf("ctx 取消后 Wait 没有返回错误")
+	}
+}
+
+func TestRateLimitedAudioServiceDisabled(t *testing.T) {
+	inner := &fakeAudio{calls: make(chan string, 1)}
+	if s := NewRateLimitedAudioService(inner, 0, 1); s != AudioService(inner) {
+		t.Errorf("rate <= 0 时应直接返回原服务")
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 普通话 TTS 大约每秒 4.5 个字，用来预估一条叫卖的播放时长
+	charsPerSecond = 4.5
+	// 两条叫卖之间留出的空隙，给客户端切换音频的时间
+	playGap = 2 * time.Second
+	// 没有可播任务时的兜底轮询间隔
+	idleRecheck = 5 * time.Second
+)
+
+// NextEventData HAWKING_NEXT 消息体：服务端下发的“下一条该播什么”
+// 同一门店的所有音箱都以这条消息为准，保证播放顺序一致
+type NextEventData struct {
+	SessionID   string              `json:"session_id"`
+	Seq         int64               `json:"seq"` // 单调递增的播放序号，客户端可据此丢弃乱序消息
+	ProductID   string              `json:"product_id"`
+	TaskID      string              `json:"task_id"`
+	Product     *models.HawkingTask `json:"product"`
+	VoiceType   string              `json:"voice_type"`
+	DurationSec float64             `json:"duration_sec"` // 预估播放时长（秒），到点后服务端会推下一条
+}
+
+// RotationEngine 单个 Session 的轮播引擎
+// 规则：
+//  1. 只有处于生效时段且合成完成的任务参与轮播
+//  2. 每个任务播完后至少间隔 IntervalSec 才能再次播放
+//  3. Priority > 0 的任务可以插队：立即播一次，之后和其他任务一样按权重轮播，
+//     距上次插队满 IntervalSec 后才能再次插队（IntervalSec 为 0 时只插队一次），避免独占播放
+//  4. 不插队时，所有可播任务按 Weight 做平滑加权轮询，权重越高出现越频繁
+//
+// RotationEngine 不加锁，调用方需持有 Session 的写锁
+type RotationEngine struct {
+	current map[string]int       // 平滑加权轮询的当前权重，key 为任务 key
+	cutIns  map[string]time.Time // 高优先级任务上次插队的时间
+	seq     int64
+}
+
+func NewRotationEngine() *RotationEngine {
+	return &RotationEngine{current: make(map[string]int), cutIns: make(map[string]time.Time)}
+}
+
+// Next 从任务列表中选出下一条要播放的任务，并记录播放时间
+// 返回 nil 时，wait 表示最早还要等多久才会有任务可播（0 表示当前没有任何可播任务）
+func (e *RotationEngine) Next(tasks []*models.HawkingTask, now time.Time) (next *models.HawkingTask, seq int64, wait time.Duration) {
+	// 1. 清理已经被移除的任务的权重和插队记录
+	alive := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		alive[taskKey(t)] = true
+	}
+	for key := range e.current {
+		if !alive[key] {
+			delete(e.current, key)
+		}
+	}
+	for key := range e.cutIns {
+		if !alive[key] {
+			delete(e.cutIns, key)
+		}
+	}
+
+	// 2. 筛选出间隔已到、可以播放的任务
+	var eligible []*models.HawkingTask
+	for _, t := range tasks {
+		if !t.Active || t.Status != models.TaskReady || t.AudioURL == "" {
+			continue
+		}
+		if remain := t.CooldownRemaining(now); remain > 0 {
+			if wait == 0 || remain < wait {
+				wait = remain
+			}
+			continue
+		}
+		eligible = append(eligible, t)
+	}
+	if len(eligible) == 0 {
+		return nil, e.seq, wait
+	}
+
+	// 按任务的排列顺序排序，权重相同时按店主排好的顺序轮播；同时避免 map 遍历顺序带来的抖动
+	sortTasks(eligible)
+
+	// 3. 还能插队的高优先级任务先播，优先级相同时按排列顺序
+	for _, t := range eligible {
+		if e.canCutIn(t, now) && (next == nil || t.Priority > next.Priority) {
+			next = t
+		}
+	}
+	if next != nil {
+		e.cutIns[taskKey(next)] = now
+	} else {
+		next = e.weighted(eligible)
+	}
+
+	playedAt := now
+	next.LastPlayedAt = &playedAt
+	e.seq++
+	return next, e.seq, 0
+}
+
+// canCutIn 高优先级任务从未插队过，或距上次插队已满 IntervalSec
+func (e *RotationEngine) canCutIn(task *models.HawkingTask, now time.Time) bool {
+	if task.Priority <= 0 {
+		return false
+	}
+	last, ok := e.cutIns[taskKey(task)]
+	if !ok {
+		return true
+	}
+	return task.IntervalSec > 0 && !now.Before(last.Add(time.Duration(task.IntervalSec)*time.Second))
+}
+
+// weighted 平滑加权轮询（nginx 同款算法），candidates 不能为空
+func (e *RotationEngine) weighted(candidates []*models.HawkingTask) (next *models.HawkingTask) {
+	total := 0
+	for _, t := range candidates {
+		w := t.Weight
+		if w <= 0 {
+			w = 1
+		}
+		key := taskKey(t)
+		e.current[key] += w
+		total += w
+		if next == nil || e.current[key] > e.current[taskKey(next)] {
+			next = t
+		}
+	}
+	e.current[taskKey(next)] -= total
+	return next
+}
+
+// estimatePlayDuration 根据文案长度预估播放时长
+func estimatePlayDuration(task *models.HawkingTask) time.Duration {
+	return estimateTextDuration(task.Text)
+}
+
+// estimateTextDuration 预估一段文案的播放时长（含切换间隙）
+func estimateTextDuration(text string) time.Duration {
+	chars := utf8.RuneCountInString(text)
+	return time.Duration(float64(chars)/charsPerSecond*float64(time.Second)) + playGap
+}
+
+// taskKey 任务在 Session 内的唯一标识
+func taskKey(task *models.HawkingTask) string {
+	return task.ID
+}
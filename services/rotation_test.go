@@ -0,0 +1,75 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"testing"
+	"time"
+)
+
+func TestRotationEngineNext(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	ready := func(id string, position int, weight int, priority int, intervalSec int) *models.HawkingTask {
+		return &models.HawkingTask{
+			ID: id, Position: position, Weight: weight, Priority: priority, IntervalSec: intervalSec,
+			Active: true, Status: models.TaskReady, AudioURL: "/static/audio/" + id + ".mp3",
+		}
+	}
+	playedAgo := func(task *models.HawkingTask, ago time.Duration) *models.HawkingTask {
+		at := start.Add(-ago)
+		task.LastPlayedAt = &at
+		return task
+	}
+	queued := ready("queued", 0, 1, 0, 0)
+	queued.Status = models.TaskQueued
+	inactive := ready("inactive", 1, 1, 0, 0)
+	inactive.Active = false
+
+	// 每轮间隔 1 秒；没有可播任务时记为 "wait <还要等多久>"
+	cases := []struct {
+		name  string
+		tasks []*models.HawkingTask
+		want  []string
+	}{
+		{"同权重按顺序轮播", []*models.HawkingTask{ready("b", 1, 1, 0, 0), ready("a", 0, 1, 0, 0), ready("c", 2, 1, 0, 0)},
+			[]string{"a", "b", "c", "a", "b", "c"}},
+		{"权重 3:1 平滑交错", []*models.HawkingTask{ready("a", 0, 3, 0, 0), ready("b", 1, 1, 0, 0)},
+			[]string{"a", "a", "b", "a", "a", "a", "b", "a"}},
+		{"权重 0 按 1 计算", []*models.HawkingTask{ready("a", 0, 0, 0, 0), ready("b", 1, 1, 0, 0)},
+			[]string{"a", "b", "a", "b"}},
+		{"紧急任务插队一次后按权重轮播，普通任务照常播放", []*models.HawkingTask{ready("a", 0, 1, 0, 0), ready("urgent", 1, 1, 1, 0)},
+			[]string{"urgent", "a", "urgent", "a", "urgent", "a"}},
+		{"多个紧急任务按优先级依次插队", []*models.HawkingTask{ready("a", 0, 1, 0, 0), ready("u1", 1, 1, 1, 0), ready("u2", 2, 1, 2, 0)},
+			[]string{"u2", "u1", "a", "u1", "u2"}},
+		{"高优先级冷却时轮到普通任务", []*models.HawkingTask{ready("a", 0, 1, 0, 0), ready("urgent", 1, 1, 1, 3)},
+			[]string{"urgent", "a", "a", "urgent", "a"}},
+		{"播放间隔", []*models.HawkingTask{ready("a", 0, 1, 0, 3), ready("b", 1, 1, 0, 3)},
+			[]string{"a", "b", "wait 1s", "a", "b"}},
+		{"空列表", nil,
+			[]string{"wait 0s"}},
+		{"全部冷却中取最早可播的时间", []*models.HawkingTask{playedAgo(ready("a", 0, 1, 0, 10), 4*time.Second), playedAgo(ready("b", 1, 1, 0, 10), 8*time.Second)},
+			[]string{"wait 2s", "wait 1s", "b"}},
+		{"未就绪或不在时段内的任务不参与", []*models.HawkingTask{queued, inactive},
+			[]string{"wait 0s"}},
+	}
+
+	for _, c := range cases {
+		engine := NewRotationEngine()
+		var plays int64
+		for round, want := range c.want {
+			now := start.Add(time.Duration(round) * time.Second)
+			next, seq, wait := engine.Next(c.tasks, now)
+
+			got := "wait " + wait.String()
+			if next != nil {
+				got = next.ID
+				plays++
+				if seq != plays {
+					t.Errorf("%s 第 %d 轮: seq = %d, want %d", c.name, round+1, seq, plays)
+				}
+			}
+			if got != want {
+				t.Errorf("%s 第 %d 轮: got %s, want %s", c.name, round+1, got, want)
+			}
+		}
+	}
+}
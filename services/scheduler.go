@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"hawker-backend/logic"
 	"hawker-backend/models"
@@ -11,16 +12,22 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type HawkingSession struct {
 	ID        string
+	StoreID   string // 所属门店
+	ZoneID    string // 所属分区，为空表示门店默认会话（此时 ID 即 StoreID）
 	VoiceType string
-	// 该 Session 下的任务列表，key 是 ProductID
+	// 该 Session 下的任务列表，key 是任务 ID（同一商品可以有多个任务）
 	ActiveTasks  map[string]*models.HawkingTask
 	currentIntro *models.HawkingIntro
 	mu           sync.RWMutex
@@ -29,68 +36,357 @@ type HawkingSession struct {
 	SessionCtx    context.Context // Session 的总开关（只有关闭 Session 时才取消）
 	SessionCancel context.CancelFunc
 
+	batchCtx    context.Context    // 当前这一波合成任务的 ctx，切换音色时整体取消
 	BatchCancel context.CancelFunc // 🌟 专门用于取消“当前这一波”合成任务
 
 	taskNotify chan struct{}
 	IsRunning  int32
 
+	rotation   *RotationEngine // 轮播引擎：决定下一条播什么
+	playNotify chan struct{}   // 有新任务合成完成时唤醒空闲的轮播循环
+	preempt    chan struct{}   // 临时广播插播或暂停，打断当前播放
+	holdUntil  time.Time       // 插播期间暂停轮播，到点后恢复
+	Paused     bool            // 店主暂停叫卖：任务和音频保留，轮播停止，直到恢复
+
+	inflight map[string]bool // 已提交到合成执行器、尚未完成的任务
+
 	VoiceVersion int // 音色版本
+
+	// --- 增量同步 ---
+	Epoch      string            // 会话实例 ID：会话销毁重建后修订号从头开始，客户端据此判断增量是否可用
+	Revision   int64             // 修订号：任务或会话每变化一次加一
+	tombstones map[string]int64  // 已删除任务 ID -> 删除时的修订号
+	deltaFloor int64             // 早于这个修订号的删除记录已丢弃（或服务重启丢失），只能拿全量
+	sentIntro  map[string]string // 各音色最近一次随播放事件下发的开场白池版本
+
+	saveMu sync.Mutex // 串行化落库，保证后一次快照不会被前一次覆盖
 }
 
 // 建议的消息结构
 type PlayEventData struct {
 	SessionID string `json:"session_id"`
 	ProductID string `json:"product_id"`
+	TaskID    string `json:"task_id"`
 	// 🌟 只有在音色变更后的第一个任务，或者 Pool 发生变化时才携带，平时为 nil
-	IntroPool []*models.HawkingIntro `json:"intro_pool,omitempty"`
-	Product   *models.HawkingTask    `json:"product"`    // 商品叫卖任务
-	VoiceType string                 `json:"voice_type"` // 全局同步音色
+	IntroPool        []*models.HawkingIntro `json:"intro_pool,omitempty"`
+	IntroPoolVersion string                 `json:"intro_pool_version"` // 始终携带，客户端版本不一致时可拉取快照
+	Product          *models.HawkingTask    `json:"product"`            // 商品叫卖任务
+	VoiceType        string                 `json:"voice_type"`         // 全局同步音色
 }
 
 type HawkingScheduler struct {
 	productRepo  repositories.ProductRepository
-	introRepo    repositories.IntroRepository // 👈 新增：开场白仓库
+	introRepo    repositories.IntroRepository          // 👈 新增：开场白仓库
+	sessionRepo  repositories.HawkingSessionRepository // 👈 Session 持久化，重启后恢复
 	audioService AudioService
+	executor     *SynthesisExecutor // 全局共享的合成执行器
 	Hub          *Hub
 
 	sessions  map[string]*HawkingSession // 👈 管理多个 Session
 	sessionMu sync.RWMutex
+
+	queueMode bool // 队列合成模式：TTS 交给 QueueWorker，见 EnableSynthesisQueue
+
+	// 会话 -> 所属门店，会话销毁后仍保留，保证最后几条消息也能发到门店房间
+	storeOf map[string]string
+	roomsMu sync.RWMutex
 }
 
-func NewHawkingScheduler(repo repositories.ProductRepository, introRepo repositories.IntroRepository, audio AudioService, hub *Hub) *HawkingScheduler {
+func NewHawkingScheduler(repo repositories.ProductRepository, introRepo repositories.IntroRepository, sessionRepo repositories.HawkingSessionRepository, audio AudioService, executor *SynthesisExecutor, hub *Hub) *HawkingScheduler {
 	return &HawkingScheduler{
 		productRepo:  repo,
 		introRepo:    introRepo,
+		sessionRepo:  sessionRepo,
 		audioService: audio,
+		executor:     executor,
 		Hub:          hub,
 		sessions:     make(map[string]*HawkingSession, 2),
+		storeOf:      make(map[string]string),
 	}
 }
 
-func (s *HawkingScheduler) StartSession(sessionID string, voiceType string) {
-	s.sessionMu.Lock()
-	defer s.sessionMu.Unlock()
+// SessionKey 会话 ID 的规范形式（小写 UUID）
+// iOS 端传的是大写 UUID，数据库读出来的是小写，所有按会话 ID 查找的地方都必须先经过这里，否则同一门店会出现两个并行的会话
+func SessionKey(id string) string {
+	return strings.ToLower(strings.TrimSpace(id))
+}
 
-	// 1. 如果 Session 已存在且在运行，则跳过
-	if sess, ok := s.sessions[sessionID]; ok && atomic.LoadInt32(&sess.IsRunning) == 1 {
-		return
-	}
+// newSession 构造一个空的 Session，调用方负责注册到 s.sessions 并启动循环
 
-	// 2. 初始化新 Session
+func newSession(sessionID string, storeID string, zoneID string, voiceType string) *HawkingSession {
 	ctx, cancel := context.WithCancel(context.Background())
-	sess := &HawkingSession{
-		ID:            sessionID,
+	batchCtx, batchCancel := context.WithCancel(ctx)
+	return &HawkingSession{
+		ID:            SessionKey(sessionID),
+		StoreID:       SessionKey(storeID),
+		ZoneID:        SessionKey(zoneID),
 		VoiceType:     voiceType,
 		ActiveTasks:   make(map[string]*models.HawkingTask),
 		taskNotify:    make(chan struct{}, 1),
 		SessionCtx:    ctx,
 		SessionCancel: cancel,
+		batchCtx:      batchCtx,
+		BatchCancel:   batchCancel,
 		IsRunning:     1,
+		rotation:      NewRotationEngine(),
+		playNotify:    make(chan struct{}, 1),
+		preempt:       make(chan struct{}, 1),
+		inflight:      make(map[string]bool),
+		Epoch:         uuid.NewString(),
+		tombstones:    make(map[string]int64),
+		sentIntro:     make(map[string]string),
 	}
-	s.sessions[sessionID] = sess
+}
 
-	// 3. 启动该 Session 的独立叫卖协程
+// startSession 启动 Session 的合成循环和轮播循环
+func (s *HawkingScheduler) startSession(sess *HawkingSession) {
 	go s.runSessionLoop(sess)
+	go s.runPlaylistLoop(sess)
+}
+
+func (s *HawkingScheduler) StartSession(sessionID string, voiceType string) {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	// 1. 如果 Session 已存在且在运行，则跳过
+	if sess, ok := s.sessions[SessionKey(sessionID)]; ok && atomic.LoadInt32(&sess.IsRunning) == 1 {
+		return
+	}
+
+	// 2. 初始化新 Session
+	sess := newSession(sessionID, sessionID, "", voiceType)
+	s.sessions[SessionKey(sessionID)] = sess
+	s.rememberStore(sess)
+
+	// 3. 启动该 Session 的独立叫卖协程
+	s.startSession(sess)
+}
+
+// Restore 从数据库恢复所有 Session，并为每个 Session 重新启动 runSessionLoop
+// 应在服务启动、开始接收请求之前调用
+func (s *HawkingScheduler) Restore() error {
+	records, err := s.sessionRepo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	var restored []*HawkingSession
+	for _, record := range records {
+		if len(record.Tasks) == 0 {
+			// 空 Session 没有恢复的意义，顺手清理
+			s.sessionRepo.DeleteSession(record.ID)
+			continue
+		}
+
+		// 旧版本按客户端传来的原样（可能是大写）落库，同一个会话可能有大小写不同的两条记录
+		// 合并到规范 ID 下，旧记录删掉，恢复完成后按规范 ID 重新落库
+		if sess, exists := s.sessions[SessionKey(record.ID)]; exists {
+			sess.mu.Lock()
+			for i := range record.Tasks {
+				task := record.Tasks[i].Task
+				if _, dup := sess.ActiveTasks[task.ID]; task.ID == "" || !dup {
+					s.restoreTaskLocked(sess, &task)
+				}
+			}
+			sess.mu.Unlock()
+			s.sessionRepo.DeleteSession(record.ID)
+			log.Printf("♻️ 合并大小写不同的重复 Session [%s]", record.ID)
+			continue
+		}
+
+		// 分区上线之前的记录没有 StoreID，那时 SessionID 就是 StoreID
+		storeID := record.StoreID
+		if storeID == "" {
+			storeID = record.ID
+		}
+		sess := newSession(record.ID, storeID, record.ZoneID, record.VoiceType)
+		sess.VoiceVersion = record.VoiceVersion
+		sess.Paused = record.Paused
+		// 删除记录没有落库，重启前的修订号只能拿全量
+		if record.Epoch != "" {
+			sess.Epoch = record.Epoch
+		}
+		sess.Revision = record.Revision
+		sess.deltaFloor = record.Revision
+		for i := range record.Tasks {
+			task := record.Tasks[i].Task
+			s.restoreTaskLocked(sess, &task)
+		}
+		if record.ID != sess.ID {
+			s.sessionRepo.DeleteSession(record.ID)
+		}
+		s.sessions[sess.ID] = sess
+		restored = append(restored, sess)
+	}
+
+	for _, sess := range restored {
+		renumberTasksLocked(sess)
+		s.rememberStore(sess)
+		s.persistSession(sess)
+		s.startSession(sess)
+		// 唤醒一次，把重启前没合成完的任务接着做完
+		sess.notify()
+		// 重启前还在退避中的任务，原来的重试定时器已经丢了，重新定一个
+		sess.armRetryWakeup(time.Now())
+
+		log.Printf("♻️ 已恢复 Session [%s]，共 %d 个任务", sess.ID, len(sess.ActiveTasks))
+	}
+	return nil
+}
+
+// armRetryWakeup 在最早的 NextRetryAt 到期时唤醒 Session 循环，没有退避中的任务时不做任何事
+func (sess *HawkingSession) armRetryWakeup(now time.Time) {
+	sess.mu.RLock()
+	var earliest *time.Time
+	for _, task := range sess.ActiveTasks {
+		if task.Status != models.TaskQueued || task.NextRetryAt == nil || !task.NextRetryAt.After(now) {
+			continue
+		}
+		if earliest == nil || task.NextRetryAt.Before(*earliest) {
+			earliest = task.NextRetryAt
+		}
+	}
+	sess.mu.RUnlock()
+
+	if earliest != nil {
+		time.AfterFunc(earliest.Sub(now), func() { sess.notify() })
+	}
+}
+
+// restoreTaskLocked 把落库的任务放回会话，调用方必须持有 sess.mu 写锁（或会话尚未启动）
+func (s *HawkingScheduler) restoreTaskLocked(sess *HawkingSession, task *models.HawkingTask) {
+	s.restoreTaskStatus(task)
+	task.Active = task.ActiveAt(time.Now())
+	// 任务 ID 上线之前落库的任务没有 ID，补一个
+	if task.ID == "" {
+		task.ID = uuid.NewString()
+	}
+	sess.ActiveTasks[task.ID] = task
+}
+
+// restoreTaskStatus 修正从数据库恢复的任务状态
+// 重启前正在合成的任务重新排队；音频文件在重启期间被清理的，也需要重新合成
+func (s *HawkingScheduler) restoreTaskStatus(task *models.HawkingTask) {
+	if task.Status == "" {
+		// 旧版本落库的数据没有状态字段，根据音频地址推断
+		task.Status = models.TaskQueued
+		if task.AudioURL != "" {
+			task.Status = models.TaskReady
+		}
+	}
+
+	switch task.Status {
+	case models.TaskSynthesizing, models.TaskSuperseded:
+		task.Status = models.TaskQueued
+		task.StatusChangedAt = time.Now()
+	case models.TaskReady:
+		fileName, _ := s.generateFileName(task, task.VoiceType)
+		if !s.checkAudioExists(fileName) {
+			task.Status = models.TaskQueued
+			task.StatusChangedAt = time.Now()
+			task.AudioURL = ""
+		}
+	}
+}
+
+// HasSession 判断 Session 是否存在（用于区分“恢复会话”和“空会话”）
+func (s *HawkingScheduler) HasSession(sessionID string) bool {
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	_, exists := s.sessions[SessionKey(sessionID)]
+	return exists
+}
+
+// notify 唤醒 runSessionLoop，信号已满时直接丢弃（循环处理完会重新扫描任务）
+func (sess *HawkingSession) notify() bool {
+	select {
+	case sess.taskNotify <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// wakePlaylist 唤醒空闲中的轮播循环
+func (sess *HawkingSession) wakePlaylist() {
+	select {
+	case sess.playNotify <- struct{}{}:
+	default:
+	}
+}
+
+// rememberStore 记录会话所属的门店，用于把会话消息同时发到门店房间
+func (s *HawkingScheduler) rememberStore(sess *HawkingSession) {
+	s.roomsMu.Lock()
+	s.storeOf[sess.ID] = sess.StoreID
+	s.roomsMu.Unlock()
+}
+
+// sessionRooms 会话消息的目标房间：会话房间 + 所属门店房间
+func (s *HawkingScheduler) sessionRooms(sessionID string) []string {
+	sessionID = SessionKey(sessionID)
+	s.roomsMu.RLock()
+	storeID, ok := s.storeOf[sessionID]
+	s.roomsMu.RUnlock()
+	if !ok {
+		storeID = sessionID // 门店默认会话的 ID 就是 StoreID
+	}
+	return []string{SessionRoom(sessionID), StoreRoom(storeID)}
+}
+
+// broadcastToSession 推送给订阅了该会话或其所属门店的客户端
+func (s *HawkingScheduler) broadcastToSession(sessionID string, payload models.WSMessage) {
+	s.Hub.BroadcastToRooms(s.sessionRooms(sessionID), payload)
+}
+
+// broadcastSnapshot 推送会话的任务快照
+func (s *HawkingScheduler) broadcastSnapshot(snapshot *models.TasksSnapshotData) {
+	s.Hub.BroadcastTaskBundle(s.sessionRooms(snapshot.SessionID), snapshot)
+}
+
+// interruptPlaylist 打断轮播循环正在等待的条目（插播、暂停时使用）
+func (sess *HawkingSession) interruptPlaylist() {
+	select {
+	case sess.preempt <- struct{}{}:
+	default:
+	}
+}
+
+// persistSession 将 Session 当前状态整体落库
+func (s *HawkingScheduler) persistSession(sess *HawkingSession) {
+	sess.saveMu.Lock()
+	defer sess.saveMu.Unlock()
+
+	sess.mu.RLock()
+	record := &models.HawkingSessionRecord{
+		ID:           sess.ID,
+		StoreID:      sess.StoreID,
+		ZoneID:       sess.ZoneID,
+		VoiceType:    sess.VoiceType,
+		VoiceVersion: sess.VoiceVersion,
+		Paused:       sess.Paused,
+		Epoch:        sess.Epoch,
+		Revision:     sess.Revision,
+		Tasks:        make([]models.HawkingTaskRecord, 0, len(sess.ActiveTasks)),
+	}
+	for _, task := range sess.ActiveTasks {
+		record.Tasks = append(record.Tasks, models.HawkingTaskRecord{SessionID: sess.ID, Task: *task})
+	}
+	sess.mu.RUnlock()
+
+	if err := s.sessionRepo.SaveSession(record); err != nil {
+		log.Printf("❌ Session [%s] 落库失败: %v", sess.ID, err)
+	}
+}
+
+// deleteSessionRecord 删除 Session 的持久化记录
+func (s *HawkingScheduler) deleteSessionRecord(sessionID string) {
+	if err := s.sessionRepo.DeleteSession(sessionID); err != nil {
+		log.Printf("❌ Session [%s] 删除持久化记录失败: %v", sessionID, err)
+	}
 }
 
 func (s *HawkingScheduler) runSessionLoop(sess *HawkingSession) {
@@ -109,66 +405,302 @@ func (s *HawkingScheduler) runSessionLoop(sess *HawkingSession) {
 			log.Printf("🔔 Session [%s] 被唤醒，开始检查新任务", sess.ID)
 		}
 
-		// --- 2. 处理任务 ---
-		sess.mu.RLock()
-		// 提取还没合成的任务（按需处理）
-		var pendingTasks []*models.HawkingTask
-		for _, t := range sess.ActiveTasks {
-			if !t.IsSynthesized { // 关键：只处理未合成的
-				pendingTasks = append(pendingTasks, t)
-			}
+		// --- 2. 把未合成的任务交给全局合成执行器 ---
+		s.runSynthesisBatch(sess)
+	}
+}
+
+// runSynthesisBatch 将 Session 中所有待合成的任务提交给全局合成执行器
+// AddTask 唤醒和音色切换都走这里，同一任务同时只会有一份在途
+func (s *HawkingScheduler) runSynthesisBatch(sess *HawkingSession) {
+	now := time.Now()
+	sess.mu.Lock()
+	ctx, version := sess.batchCtx, sess.VoiceVersion
+	var pendingTasks []*models.HawkingTask
+	for _, t := range sess.ActiveTasks {
+		// 关键：只处理排队中的（每个任务按自己的音色合成，Session 内可以混用多种音色）
+		if t.Status != models.TaskQueued || sess.inflight[taskKey(t)] {
+			continue
 		}
-		sess.mu.RUnlock()
+		// 还在退避中的任务等定时器唤醒
+		if t.NextRetryAt != nil && now.Before(*t.NextRetryAt) {
+			continue
+		}
+		sess.inflight[taskKey(t)] = true
+		pendingTasks = append(pendingTasks, t)
+	}
+	sess.mu.Unlock()
 
-		if len(pendingTasks) == 0 {
+	for _, task := range pendingTasks {
+		task := task
+		if s.queueMode {
+			// 队列模式本实例不调用 TTS，只是等文件出现，不占执行器的 worker，
+			// 否则几个排队中的商品就能占满 worker，把紧急广播堵在后面
+			go s.synthesizeTask(ctx, sess, task, version)
 			continue
 		}
+		s.executor.Submit(&SynthesisJob{
+			SessionID: sess.ID,
+			Ctx:       ctx,
+			Run: func(ctx context.Context) {
+				s.synthesizeTask(ctx, sess, task, version)
+			},
+		})
+	}
+}
+
+// synthesizeTask 在执行器的 worker 中合成单个任务，并把结果写回 Session
+func (s *HawkingScheduler) synthesizeTask(ctx context.Context, sess *HawkingSession, task *models.HawkingTask, version int) {
+	key := taskKey(task)
+
+	// 1. 开工前确认任务仍然有效：排队期间可能切换了音色或被移除
+	sess.mu.Lock()
+	if ctx.Err() != nil || sess.VoiceVersion != version || sess.ActiveTasks[key] != task || task.Status != models.TaskQueued {
+		delete(sess.inflight, key)
+		sess.mu.Unlock()
+		// 交回 Session 循环，按最新版本重新派发
+		sess.notify()
+		return
+	}
+	event := s.transitionLocked(sess, task, models.TaskSynthesizing, "")
+	sess.mu.Unlock()
+	s.emitStatusEvents(event)
+
+	// 2. 执行合成，单次合成加上超时，防止连接卡死占住 worker
+	product, err := s.productRepo.FindByID(task.ProductID)
+	var audioURL, script string
+	if err == nil {
+		attemptCtx, cancel := context.WithTimeout(ctx, s.executor.Policy.Timeout)
+		audioURL, script, err = s.executeHawking(attemptCtx, product, task)
+		cancel()
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 商品已被删除，重试也没用；数据库连接等临时错误照常退避重试
+		err = &ProviderError{Message: fmt.Sprintf("商品不存在: %v", err)}
+	}
+
+	sess.mu.Lock()
+	delete(sess.inflight, key)
+	// 🌟 双重校验：音色版本变了，或者任务已被移除/单独换了音色，直接丢弃本次结果
+	// 此时任务已被 ChangeSessionVoice / SetTaskVoice / RemoveTask 切到了别的状态
+	if ctx.Err() != nil || sess.VoiceVersion != version || sess.ActiveTasks[key] != task || task.Status != models.TaskSynthesizing {
+		sess.mu.Unlock()
+		sess.notify()
+		return
+	}
+
+	if err != nil {
+		s.handleSynthesisFailureLocked(sess, task, err)
+		return
+	}
+
+	task.AudioURL = audioURL
+	task.Text = script
+	task.Attempts = 0
+	task.LastError = ""
+	task.NextRetryAt = nil
+	event = s.transitionLocked(sess, task, models.TaskReady, "")
+	snapshot := *task
+	sess.mu.Unlock()
+	s.persistSession(sess)
+	s.emitStatusEvents(event)
+	sess.wakePlaylist()
 
-		for _, task := range pendingTasks {
-			// 增加一层校验：如果任务要求的音色和 Session 当前音色不符，说明是旧信号，跳过
-			if task.VoiceType != sess.VoiceType {
-				continue
+	// 🌟 获取该音色对应的完整开场白池，客户端已经有这一版的话就不重复下发
+	introPool := s.GetIntroPoolByVoice(snapshot.VoiceType)
+	sess.mu.Lock()
+	introPool, introVersion := sess.introPoolForPlayLocked(snapshot.VoiceType, introPool)
+	sess.mu.Unlock()
+
+	log.Printf("📡 广播新资源: %s (开场白池 %s, 下发: %v)", product.Name, introVersion, introPool != nil)
+	// 📢 仅在此时广播：合成好了，告诉客户端“加菜了”
+	s.broadcastPlayEventToSession(sess.ID, product, &snapshot, introPool, introVersion)
+}
+
+// TaskFailedData HAWKING_TASK_FAILED 消息体：任务已放弃重试
+type TaskFailedData struct {
+	SessionID string              `json:"session_id"`
+	ProductID string              `json:"product_id"`
+	TaskID    string              `json:"task_id"`
+	Reason    string              `json:"reason"`
+	Attempts  int                 `json:"attempts"`
+	Permanent bool                `json:"permanent"` // true 表示供应商明确拒绝，重试无意义
+	Product   *models.HawkingTask `json:"product"`
+}
+
+// handleSynthesisFailureLocked 记录失败并安排重试，超过次数或永久性错误则标记失败并通知客户端
+// 调用方必须持有 sess.mu 写锁，函数返回前会释放
+func (s *HawkingScheduler) handleSynthesisFailureLocked(sess *HawkingSession, task *models.HawkingTask, err error) {
+	policy := s.executor.Policy
+	permanent := IsPermanentSynthesisError(err)
+	task.Attempts++
+	task.LastError = err.Error()
+
+	if permanent || task.Attempts >= policy.MaxAttempts {
+		task.NextRetryAt = nil
+		event := s.transitionLocked(sess, task, models.TaskFailed, task.LastError)
+		data := TaskFailedData{
+			SessionID: sess.ID,
+			ProductID: task.ProductID,
+			TaskID:    task.ID,
+			Reason:    task.LastError,
+			Attempts:  task.Attempts,
+			Permanent: permanent,
+		}
+		snapshot := *task
+		data.Product = &snapshot
+		sess.mu.Unlock()
+		s.persistSession(sess)
+
+		log.Printf("❌ 合成彻底失败 [%s] (第 %d 次): %v", task.ProductID, data.Attempts, err)
+		s.emitStatusEvents(event)
+		s.broadcastToSession(sess.ID, models.WSMessage{Type: "HAWKING_TASK_FAILED", Data: data})
+		return
+	}
+
+	delay := policy.Backoff(task.Attempts)
+	nextRetry := time.Now().Add(delay)
+	task.NextRetryAt = &nextRetry
+	attempts := task.Attempts
+	event := s.transitionLocked(sess, task, models.TaskQueued, task.LastError)
+	sess.mu.Unlock()
+	s.persistSession(sess)
+	s.emitStatusEvents(event)
+
+	log.Printf("⚠️ 合成失败 [%s] (第 %d 次)，%v 后重试: %v", task.ProductID, attempts, delay, err)
+	time.AfterFunc(delay, func() { sess.notify() })
+}
+
+// runPlaylistLoop 服务端轮播循环：按轮播引擎的结果依次推送 HAWKING_NEXT
+func (s *HawkingScheduler) runPlaylistLoop(sess *HawkingSession) {
+	for {
+		sess.mu.Lock()
+		// 暂停期间不下发任何条目，等恢复时被唤醒
+		if sess.Paused {
+			sess.mu.Unlock()
+			if !s.waitPlaylist(sess, idleRecheck, sess.playNotify) {
+				return
 			}
-			product, err := s.productRepo.FindByID(task.ProductID)
-			if err != nil {
-				continue
+			continue
+		}
+		// 插播期间暂停轮播，等广播播完再继续
+		if hold := time.Until(sess.holdUntil); hold > 0 {
+			sess.mu.Unlock()
+			if !s.waitPlaylist(sess, hold, nil) {
+				return
+			}
+			continue
+		}
+		tasks := make([]*models.HawkingTask, 0, len(sess.ActiveTasks))
+		for _, t := range sess.ActiveTasks {
+			tasks = append(tasks, t)
+		}
+		next, seq, wait := sess.rotation.Next(tasks, time.Now())
+		var data NextEventData
+		if next != nil {
+			snapshot := *next
+			data = NextEventData{
+				SessionID:   sess.ID,
+				Seq:         seq,
+				ProductID:   next.ProductID,
+				TaskID:      next.ID,
+				Product:     &snapshot,
+				VoiceType:   next.VoiceType,
+				DurationSec: estimatePlayDuration(next).Seconds(),
 			}
+			wait = estimatePlayDuration(next)
+		}
+		sess.mu.Unlock()
 
-			// 执行合成
-			audioURL, script, err := s.executeHawking(sess.SessionCtx, product, task)
-			if err != nil {
-				log.Printf("❌ 合成失败: %v", err)
-				continue
+		// 正在播放时不响应唤醒，避免把当前这条截断
+		var wake <-chan struct{}
+		if next != nil {
+			s.broadcastToSession(sess.ID, models.WSMessage{Type: "HAWKING_NEXT", Data: data})
+		} else {
+			wake = sess.playNotify
+			if wait <= 0 || wait > idleRecheck {
+				wait = idleRecheck
 			}
+		}
 
-			// 更新状态
-			sess.mu.Lock()
-			task.IsSynthesized = true
-			task.AudioURL = audioURL
-			task.Text = script
-			sess.mu.Unlock()
+		if !s.waitPlaylist(sess, wait, wake) {
+			return
+		}
+	}
+}
+
+// waitPlaylist 等待当前条目播完，期间可被唤醒或被插播打断；Session 关闭时返回 false
+func (s *HawkingScheduler) waitPlaylist(sess *HawkingSession, wait time.Duration, wake <-chan struct{}) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-sess.SessionCtx.Done():
+		return false
+	case <-wake:
+	case <-sess.preempt:
+	case <-timer.C:
+	}
+	return true
+}
+
+// PreemptPlaylists 插播：暂停门店所有会话（或指定分区）的轮播 d 时长
+// 返回需要接收插播消息的 SessionID，没有进行中会话的门店/分区也包含在内，保证订阅方能收到
+func (s *HawkingScheduler) PreemptPlaylists(storeID string, zoneID string, d time.Duration) []string {
+	storeID = SessionKey(storeID)
+	target := storeID
+	if zoneID != "" {
+		target = SessionKey(zoneID)
+	}
+	sessionIDs := []string{target}
+	until := time.Now().Add(d)
 
-			// 匹配开场白
-			//intro := s.pickIntroForSession(sess)
-			// 🌟 获取该音色对应的完整开场白池
-			introPool := s.GetIntroPoolByVoice(sess.VoiceType)
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	for _, sess := range s.sessions {
+		matched := sess.ID == target
+		if zoneID == "" {
+			matched = matched || sess.StoreID == storeID
+		}
+		if !matched {
+			continue
+		}
+		if sess.ID != target {
+			sessionIDs = append(sessionIDs, sess.ID)
+		}
 
-			log.Printf("📡 广播新资源: %s (带全量开场白池)", product.Name)
-			// 📢 仅在此时广播：合成好了，告诉客户端“加菜了”
-			s.broadcastPlayEventToSession(sess.ID, product, task, introPool)
+		sess.mu.Lock()
+		if until.After(sess.holdUntil) {
+			sess.holdUntil = until
 		}
+		sess.mu.Unlock()
+		sess.interruptPlaylist()
+	}
+	return sessionIDs
+}
+
+// SessionVoice 返回会话的默认音色，会话不存在时返回空字符串
+func (s *HawkingScheduler) SessionVoice(sessionID string) string {
+	s.sessionMu.RLock()
+	sess, exists := s.sessions[SessionKey(sessionID)]
+	s.sessionMu.RUnlock()
+	if !exists {
+		return ""
 	}
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	return sess.VoiceType
 }
 
-func (s *HawkingScheduler) broadcastPlayEventToSession(sessionID string, p *models.Product, task *models.HawkingTask, introPool []*models.HawkingIntro) {
+func (s *HawkingScheduler) broadcastPlayEventToSession(sessionID string, p *models.Product, task *models.HawkingTask, introPool []*models.HawkingIntro, introVersion string) {
 	data := PlayEventData{
-		SessionID: sessionID, // 👈 关键：标识所属会话
-		ProductID: p.ID.String(),
-		IntroPool: introPool,
-		Product:   task,
-		VoiceType: task.VoiceType,
+		SessionID:        sessionID, // 👈 关键：标识所属会话
+		ProductID:        p.ID.String(),
+		TaskID:           task.ID,
+		IntroPool:        introPool,
+		IntroPoolVersion: introVersion,
+		Product:          task,
+		VoiceType:        task.VoiceType,
 	}
-	s.Hub.Broadcast(models.WSMessage{Type: "HAWKING_PLAY_EVENT", Data: data})
+	s.broadcastToSession(sessionID, models.WSMessage{Type: "HAWKING_PLAY_EVENT", Data: data})
 }
 
 // 匹配 Session 对应的开场白
@@ -238,6 +770,26 @@ func (s *HawkingScheduler) executeHawking(ctx context.Context, p *models.Product
 	}
 
 	// 4. 文案变了或文件丢失，调用火山引擎合成
+	if s.queueMode {
+		// 队列模式：由 QueueWorker 合成，这里只等文件出现
+		// hawking_status 是队列的租约字段，不能在这里改
+		log.Printf("📬 文案已更新，已投递到合成队列: %s", p.Name)
+		audioURL, err = s.waitQueuedAudio(ctx, p.ID.String(), newFileName)
+		if err != nil {
+			log.Printf("❌ 等待队列合成失败 [%s]: %v", p.Name, err)
+			return
+		}
+		if err := ctx.Err(); err != nil {
+			return "", "", err
+		}
+		// static 目录被多个实例共享，旧版本可能还在别的实例上使用，队列模式下不清理
+		p.LastScriptHash = currentHash
+		s.productRepo.UpdateHawkingFields(p.ID.String(), map[string]interface{}{
+			"last_script_hash": p.LastScriptHash,
+		})
+		return
+	}
+
 	log.Printf("🎙️ 文案已更新，正在调用火山引擎合成音频: %s", p.Name)
 	audioURL, err = s.audioService.GenerateAudio(ctx, script, newFileName, task.VoiceType)
 	if err != nil {
@@ -264,16 +816,21 @@ func (s *HawkingScheduler) executeHawking(ctx context.Context, p *models.Product
 	// 更新哈希值准备存入数据库
 	p.LastScriptHash = currentHash
 
+	// 注意：不再重置 priority，它现在是轮播引擎的配置项
+	// last_hawked_at 表示真实播放时间，由音箱的 PLAY_FINISHED 上报更新，合成完成不代表播出
 	updates := map[string]interface{}{
 		"last_script_hash": p.LastScriptHash,
-		"last_hawked_at":   time.Now(),
-		"priority":         0,
 		"hawking_status":   "idle",
 	}
 	s.productRepo.UpdateHawkingStatus(p.ID.String(), updates)
 	return
 }
 func (s *HawkingScheduler) generateFileName(task *models.HawkingTask, voiceID string) (fileName string, hash string) {
+	return audioFileName(task, voiceID)
+}
+
+// audioFileName 音频文件名由商品、音色、文案哈希决定，QueueWorker 也按同样的规则命名
+func audioFileName(task *models.HawkingTask, voiceID string) (fileName string, hash string) {
 	// 统一使用 task.Text，它是 AddTask 时锁定的唯一真理
 	script := task.Text
 	hash = fmt.Sprintf("%x", md5.Sum([]byte(script)))[:8]
@@ -282,40 +839,75 @@ func (s *HawkingScheduler) generateFileName(task *models.HawkingTask, voiceID st
 
 // 辅助方法：检查本地文件是否还在（防止被手动删了）
 func (s *HawkingScheduler) checkAudioExists(identifier string) bool {
+	return audioExists(identifier)
+}
+
+func audioExists(identifier string) bool {
 	filePath := filepath.Join("./static/audio", identifier+".mp3")
 	_, err := os.Stat(filePath)
 	return err == nil
 }
 
 func (s *HawkingScheduler) AddTask(product *models.Product, req models.AddTaskReq, sessionID string) {
+	// 1. 懒加载：创建并启动新 Session
+	sess := s.getOrStartSession(sessionID, req.StoreID, req.ZoneID, req.VoiceType)
+
+	// 2. 生成文案（不持有锁）
+	task := newTask(product, req)
+
+	// 3. 在 Session 内部添加任务
+	sess.mu.Lock()
+	events := s.putTaskLocked(sess, task)
+	sess.mu.Unlock()
+	s.persistSession(sess)
+	s.emitStatusEvents(events...)
+
+	// 4. 唤醒信号
+	// 触发信号唤醒 Start 中的 for 循环
+	if sess.notify() {
+		log.Println("✅ 唤醒信号发送成功")
+	} else {
+		// 如果信号没发进去，说明上一次唤醒的任务还在处理中，
+		// 处理完后它会自动重新检查 mu.ActiveTasks，所以不用担心丢失。
+		log.Println("ℹ️ 调度器忙碌中，新任务已排队")
+	}
+}
+
+// getOrStartSession 获取 Session，不存在则创建并启动它的循环
+func (s *HawkingScheduler) getOrStartSession(sessionID string, storeID string, zoneID string, voiceType string) *HawkingSession {
 	s.sessionMu.Lock()
-	sess, exists := s.sessions[sessionID]
+	defer s.sessionMu.Unlock()
+	return s.getOrStartSessionLocked(sessionID, storeID, zoneID, voiceType)
+}
+
+// getOrStartSessionLocked 调用方必须持有 s.sessionMu 写锁
+func (s *HawkingScheduler) getOrStartSessionLocked(sessionID string, storeID string, zoneID string, voiceType string) *HawkingSession {
+	sess, exists := s.sessions[SessionKey(sessionID)]
 	if !exists {
-		// 1. 懒加载：创建并启动新 Session
-		ctx, cancel := context.WithCancel(context.Background())
-		sess = &HawkingSession{
-			ID:            sessionID,
-			VoiceType:     req.VoiceType,
-			ActiveTasks:   make(map[string]*models.HawkingTask),
-			taskNotify:    make(chan struct{}, 1),
-			SessionCtx:    ctx,
-			SessionCancel: cancel,
-		}
-		s.sessions[sessionID] = sess
-		go s.runSessionLoop(sess) // 启动该 Session 的独立循环
-		log.Printf("✨ 自动启动 Session [%s]", sessionID)
+		sess = newSession(sessionID, storeID, zoneID, voiceType)
+		s.sessions[SessionKey(sessionID)] = sess
+		s.rememberStore(sess)
+		s.startSession(sess) // 启动该 Session 的独立循环
+		log.Printf("✨ 自动启动 Session [%s]", sess.ID)
 	}
-	s.sessionMu.Unlock()
+	return sess
+}
 
+// newTask 根据请求构造任务并锁定文案，音色和状态由 putTaskLocked 决定
+func newTask(product *models.Product, req models.AddTaskReq) *models.HawkingTask {
 	finalText := req.Text
 
-	// 2. 确定文案场景
+	// 添加时已经过了降价时间的，直接按当前台阶的价格生成文案
+	markdownRatio := models.MarkdownRatioAt(req.Markdowns, time.Now())
+	price, originalPrice := models.MarkdownPrices(req.Price, req.OriginalPrice, markdownRatio)
+
+	// 确定文案场景
 	scene := "custom"
 	if finalText == "" {
 		// 构造一个临时 Task 传给文案生成逻辑
 		tempTask := &models.HawkingTask{
-			Price:         req.Price,
-			OriginalPrice: req.OriginalPrice,
+			Price:         price,
+			OriginalPrice: originalPrice,
 			Unit:          req.Unit,
 			MinQty:        req.MinQty,
 			ConditionUnit: req.ConditionUnit,
@@ -328,100 +920,174 @@ func (s *HawkingScheduler) AddTask(product *models.Product, req models.AddTaskRe
 		scene = "smart_generated" // 标记是生成的
 	}
 
-	// 3. 在 Session 内部添加任务
-	sess.mu.Lock()
-	key := strings.ToLower(product.ID.String())
-	sess.ActiveTasks[key] = &models.HawkingTask{
+	task := &models.HawkingTask{
+		ID:            strings.ToLower(req.TaskID),
 		ProductID:     req.ProductID,
 		CustomText:    req.Text,
 		Text:          finalText, // 锁定文案，后续音色切换全部基于此 Text
-		Price:         req.Price,
-		OriginalPrice: req.OriginalPrice,
+		Price:         price,
+		OriginalPrice: originalPrice,
 		Unit:          req.Unit,
 		MinQty:        req.MinQty,
 		ConditionUnit: req.ConditionUnit,
 		PromotionTag:  req.PromotionTag,
 		UseRepeatMode: req.UseRepeatMode,
-		VoiceType:     req.VoiceType,
 		Scene:         scene,
-		IsSynthesized: false, // 确保进入循环后被识别为 pendingTasks
+		Weight:        pickInt(req.Weight, product.Weight),
+		Priority:      pickInt(req.Priority, product.Priority),
+		IntervalSec:   pickInt(req.IntervalSec, product.IntervalSec),
+		TaskWindow:    req.TaskWindow,
+		Active:        req.TaskWindow.ActiveAt(time.Now()),
+		PromotionID:   req.PromotionID,
+		SortOrder:     req.SortOrder,
+		ProgramID:     req.ProgramID,
+
+		Markdowns:         req.Markdowns,
+		BasePrice:         req.Price,
+		BaseOriginalPrice: req.OriginalPrice,
+		MarkdownRatio:     markdownRatio,
 	}
-	sess.mu.Unlock()
+	if req.PinVoice && req.VoiceType != "" {
+		task.VoiceType = req.VoiceType
+		task.PinnedVoice = true
+	}
+	return task
+}
 
-	// 4. 唤醒信号
-	// 触发信号唤醒 Start 中的 for 循环
-	select {
-	case sess.taskNotify <- struct{}{}:
-		log.Println("✅ 唤醒信号发送成功")
-	default:
-		// 如果信号没发进去，说明上一次唤醒的任务还在处理中，
-		// 处理完后它会自动重新检查 mu.ActiveTasks，所以不用担心丢失。
-		log.Println("ℹ️ 调度器忙碌中，新任务已排队")
+// putTaskLocked 将任务放入 Session 并排队合成，修改已有任务时视为替换
+// 调用方必须持有 sess.mu 写锁
+func (s *HawkingScheduler) putTaskLocked(sess *HawkingSession, task *models.HawkingTask) []*TaskStatusEventData {
+	var events []*TaskStatusEventData
+	// 被替换的旧任务作废，新任务沿用它的 ID 和位置
+	old := replacedTaskLocked(sess, task)
+	if old != nil {
+		events = append(events, s.transitionLocked(sess, old, models.TaskCancelled, "replaced"))
+	}
+	assignTaskIDLocked(sess, task, old)
+	delete(sess.tombstones, task.ID) // 删除后又以同一 ID 加回来的（整体替换），不再算删除
+	if !task.PinnedVoice {
+		task.VoiceType = sess.VoiceType // 未固定音色的任务跟随 Session 默认音色
+	}
+	// 确保进入循环后被识别为待合成
+	events = append(events, s.transitionLocked(sess, task, models.TaskQueued, ""))
+	sess.ActiveTasks[taskKey(task)] = task
+	return events
+}
+
+// LoadPromotion 将促销场次的所有明细导入 Session
+// 每个明细映射为一个叫卖任务，有效期与促销一致，过期后由定时巡检自动移除
+// 返回成功导入的数量，以及因商品不存在或不属于该门店而跳过的明细
+func (s *HawkingScheduler) LoadPromotion(promo *models.PromotionSession, req models.LoadPromotionReq, sessionID string) (loaded int, skipped []string) {
+	window := promo.Window()
+	op := BatchOp{StoreID: promo.StoreID.String(), ZoneID: req.ZoneID, VoiceType: req.VoiceType}
+
+	// Items 已按 SortOrder 排好，这里保持顺序依次导入
+	for _, item := range promo.Items {
+		product, err := s.productRepo.FindByID(item.ProductID.String())
+		if err != nil || product.StoreID != promo.StoreID {
+			skipped = append(skipped, item.ProductName)
+			continue
+		}
+		unit := item.PromoUnit
+		if unit == "" {
+			unit = product.Unit
+		}
+
+		op.Add = append(op.Add, TaskSpec{Product: product, Req: models.AddTaskReq{
+			StoreID:       promo.StoreID.String(),
+			ZoneID:        req.ZoneID,
+			ProductID:     product.ID.String(),
+			Price:         item.PromoPrice,
+			OriginalPrice: item.OriginalPrice,
+			Unit:          unit,
+			PromotionTag:  item.PromoTag,
+			VoiceType:     req.VoiceType,
+			UseRepeatMode: req.UseRepeatMode,
+			TaskWindow:    window,
+			PromotionID:   promo.ID.String(),
+			SortOrder:     item.SortOrder,
+		}})
+		loaded++
 	}
+	// 整场促销一次性导入，只唤醒、广播一次
+	if loaded > 0 {
+		s.ApplyBatch(sessionID, op)
+	}
+
+	log.Printf("🏷️ 促销 [%s] 已导入 Session [%s]: 成功 %d, 跳过 %d", promo.Title, sessionID, loaded, len(skipped))
+	return loaded, skipped
 }
 
-func (s *HawkingScheduler) RemoveTask(sessionID string, productID string) {
+// RemoveTask 移除任务：ref 为任务 ID 时只移除该任务，为商品 ID 时移除该商品的所有任务
+func (s *HawkingScheduler) RemoveTask(sessionID string, ref string) {
 	s.sessionMu.Lock()
-	sess, exists := s.sessions[sessionID]
+	sess, exists := s.sessions[SessionKey(sessionID)]
 	if !exists {
 		s.sessionMu.Unlock()
 		return
 	}
 
 	sess.mu.Lock()
-	delete(sess.ActiveTasks, strings.ToLower(productID))
+	var events []*TaskStatusEventData
+	for _, task := range matchTasksLocked(sess, ref) {
+		events = append(events, s.removeTaskLocked(sess, task, "removed"))
+	}
 	remaining := len(sess.ActiveTasks)
 	sess.mu.Unlock()
+	s.emitStatusEvents(events...)
 
 	// ⚠️ 核心逻辑：如果任务空了，停止并移除 Session
 	if remaining == 0 {
-		sess.SessionCancel() // 停止 runSessionLoop 协程
-		delete(s.sessions, sessionID)
-		log.Printf("🗑️ Session [%s] 无任务，已自动停止并销毁", sessionID)
+		s.destroySessionLocked(sess)
 	}
 	s.sessionMu.Unlock()
+
+	if remaining > 0 {
+		s.persistSession(sess)
+	}
+}
+
+// destroySessionLocked 停止并移除 Session，调用方必须持有 s.sessionMu 写锁
+func (s *HawkingScheduler) destroySessionLocked(sess *HawkingSession) {
+	sess.SessionCancel() // 停止 runSessionLoop 协程
+	delete(s.sessions, sess.ID)
+	s.deleteSessionRecord(sess.ID)
+	log.Printf("🗑️ Session [%s] 无任务，已自动停止并销毁", sess.ID)
 }
 
 func (s *HawkingScheduler) GetActiveTasksSnapshot(sessionID string) *models.TasksSnapshotData {
 	s.sessionMu.RLock()
-	sess, exists := s.sessions[sessionID]
+	sess, exists := s.sessions[SessionKey(sessionID)]
 	s.sessionMu.RUnlock()
 
 	if !exists {
-		return &models.TasksSnapshotData{Products: []*models.HawkingTask{}, IntroPool: []*models.HawkingIntro{}}
+		return &models.TasksSnapshotData{SessionID: SessionKey(sessionID), Products: []*models.HawkingTask{}, IntroPool: []*models.HawkingIntro{}}
 	}
 
 	sess.mu.RLock()
 	defer sess.mu.RUnlock()
 
+	// 拷贝一份，避免序列化时与合成协程并发读写同一个任务；按 Position 排好，客户端直接按顺序展示
 	var products = make([]*models.HawkingTask, 0)
-	for _, task := range sess.ActiveTasks {
-		products = append(products, task)
+	voices := map[string]bool{sess.VoiceType: true}
+	for _, task := range sortedTasksLocked(sess) {
+		snapshot := *task
+		products = append(products, &snapshot)
+		voices[task.VoiceType] = true
 	}
 
-	// 仅针对该 Session 所使用的音色下发开场白池
-	introPool := s.GetIntroPoolByVoice(sess.VoiceType)
+	// 仅针对该 Session 用到的音色下发开场白池（默认音色 + 各任务固定的音色）
+	introPool := s.introPoolForVoices(voices)
 
 	return &models.TasksSnapshotData{
-		Products:  products,
-		IntroPool: introPool,
-	}
-}
-
-// 场景 B：单次播放指令
-func (s *HawkingScheduler) broadcastPlayEvent(p *models.Product, task *models.HawkingTask, introPool []*models.HawkingIntro) {
-
-	data := PlayEventData{
-		ProductID: p.ID.String(),
-		IntroPool: introPool,
-		Product:   task,
-		VoiceType: task.VoiceType,
+		SessionID:        sess.ID,
+		Paused:           sess.Paused,
+		Epoch:            sess.Epoch,
+		Revision:         sess.Revision,
+		Products:         products,
+		IntroPool:        introPool,
+		IntroPoolVersion: introPoolVersion(introPool),
 	}
-	payload := models.WSMessage{
-		Type: "HAWKING_PLAY_EVENT",
-		Data: data,
-	}
-	s.Hub.Broadcast(payload)
 }
 
 func (s *HawkingScheduler) cleanupOldVersions(productID string, voiceType string, currentFullFileName string) {
@@ -430,16 +1096,35 @@ func (s *HawkingScheduler) cleanupOldVersions(productID string, voiceType string
 	pattern := filepath.Join("static/audio", fmt.Sprintf("%s_%s_*.mp3", productID, voiceType))
 
 	files, _ := filepath.Glob(pattern)
+	inUse := s.audioFilesInUse()
 	for _, f := range files {
 		// 2. 只有文件名完全不匹配当前最新文件时才删除
 		// 这样可以保留该商品在 其它音色 下的缓存文件
-		if !strings.Contains(f, currentFullFileName) {
+		// 同一商品可能有多个任务（或在多个分区叫卖），还在被其它任务使用的文件不能删
+		if !strings.Contains(f, currentFullFileName) && !inUse[filepath.Base(f)] {
 			log.Printf("🧹 清理旧版本缓存: %s", f)
 			os.Remove(f)
 		}
 	}
 }
 
+// audioFilesInUse 返回所有会话中任务正在使用的音频文件名
+func (s *HawkingScheduler) audioFilesInUse() map[string]bool {
+	inUse := make(map[string]bool)
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	for _, sess := range s.sessions {
+		sess.mu.RLock()
+		for _, task := range sess.ActiveTasks {
+			if task.AudioURL != "" {
+				inUse[filepath.Base(task.AudioURL)] = true
+			}
+		}
+		sess.mu.RUnlock()
+	}
+	return inUse
+}
+
 // 辅助方法：匹配逻辑
 func (s *HawkingScheduler) getIntroTask(task *models.HawkingTask) *models.HawkingIntro {
 	// 逻辑核心：必须传入 task.VoiceType
@@ -470,115 +1155,143 @@ func (s *HawkingScheduler) getIntroTask(task *models.HawkingTask) *models.Hawkin
 
 }
 
-func (s *HawkingScheduler) getOrCreateSession(sessionID string) *HawkingSession {
+// ChangeSessionVoice 切换会话默认音色，所有未固定音色的任务都改用新音色；只改单个任务用 SetTaskVoice
+func (s *HawkingScheduler) ChangeSessionVoice(sessionID string, newVoiceID string) {
 	s.sessionMu.RLock()
-	defer s.sessionMu.RUnlock()
-	sess, exists := s.sessions[sessionID]
+	sess, exists := s.sessions[SessionKey(sessionID)]
+	s.sessionMu.RUnlock()
 	if !exists {
-		sess = &HawkingSession{}
+		return
 	}
-	return sess
-}
-func (s *HawkingScheduler) ChangeSessionVoice(sessionID string, newVoiceID string, targetProductIDs []string) {
-	sess := s.getOrCreateSession(sessionID)
 	sess.mu.Lock()
 
-	// 1. 取消旧批次
-	if sess.BatchCancel != nil {
-		sess.BatchCancel()
-	}
-
-	batchCtx, cancel := context.WithCancel(context.Background())
-	sess.BatchCancel = cancel
+	// 1. 取消旧批次（排队中和合成中的旧音色任务都会被打断）
+	sess.BatchCancel()
+	sess.batchCtx, sess.BatchCancel = context.WithCancel(sess.SessionCtx)
 	sess.VoiceVersion++
-	currentVersion := sess.VoiceVersion
 	sess.VoiceType = newVoiceID
-
-	// 2. 准备判断 Map
-	targetMap := make(map[string]bool)
-	for _, id := range targetProductIDs {
-		targetMap[strings.ToLower(id)] = true
-	}
+	sess.touchLocked(nil)
 
 	hasPendingTask := false // 标记是否真的需要跑后台合成
+	var events []*TaskStatusEventData
 
-	// 3. 必须遍历所有任务，确保内存里的元数据 100% 准确
+	// 2. 必须遍历所有任务，确保内存里的元数据 100% 准确
 	for _, task := range sess.ActiveTasks {
-		task.VoiceType = newVoiceID // 统一音色标识
-
-		// 基于已锁定的 task.Text 计算哈希，不再重新生成文案
-		predictedName, _ := s.generateFileName(task, newVoiceID)
-		// 第一步：先看服务端磁盘到底有没有
-		existsOnServer := s.checkAudioExists(predictedName)
-
-		if existsOnServer {
-			// 只要服务端有，无论客户端传没传，都直接复用
-			task.IsSynthesized = true
-			task.AudioURL = fmt.Sprintf("/static/audio/%s.mp3", predictedName)
-			log.Printf("♻️ 命中服务端缓存 [音色: %s]: %s", newVoiceID, predictedName)
-		} else {
-			// 如果服务端磁盘没有：
-			// 无论客户端本地有没有，都必须重新合成，否则必然 404
-			task.IsSynthesized = false
-			task.AudioURL = ""
-			hasPendingTask = true
-			log.Printf("⚡️ 无缓存，准备合成新音色 [%s]: %s", newVoiceID, predictedName)
+		// 固定了音色的任务不受 Session 默认音色影响，但旧批次被取消了，需要重新排队
+		if task.PinnedVoice {
+			if task.Status == models.TaskSynthesizing {
+				events = append(events, s.transitionLocked(sess, task, models.TaskQueued, "batch_cancelled"))
+			}
+			hasPendingTask = hasPendingTask || task.Status == models.TaskQueued
+			continue
+		}
+		// 音色没变且音频已就绪的任务无需处理
+		if task.VoiceType == newVoiceID && task.Status == models.TaskReady {
+			continue
 		}
+		taskEvents, pending := s.revoiceTaskLocked(sess, task, newVoiceID)
+		events = append(events, taskEvents...)
+		hasPendingTask = hasPendingTask || pending
 	}
 	sess.mu.Unlock()
+	s.persistSession(sess)
+	s.emitStatusEvents(events...)
+	sess.wakePlaylist()
 
-	// 4. 只有存在真正需要合成的任务时，才启动协程
+	// 3. 只有存在真正需要合成的任务时，才提交到合成执行器
 	if hasPendingTask {
-		go s.runSynthesisBatch(sess, batchCtx, currentVersion)
+		s.runSynthesisBatch(sess)
 	} else {
 		log.Printf("✅ 所有任务均命中间缓存，无需发起 TTS 合成请求")
 	}
 }
 
-// 重新合成音频
-func (s *HawkingScheduler) runSynthesisBatch(sess *HawkingSession, ctx context.Context, version int) {
-	// 批量抓取待处理任务
-	sess.mu.RLock()
-	var tasks []*models.HawkingTask
-	for _, t := range sess.ActiveTasks {
-		tasks = append(tasks, t) // 拿到当前所有任务
+// revoiceTaskLocked 将任务切换到新音色：旧音频作废，命中服务端缓存则直接就绪，否则重新排队
+// 调用方必须持有 sess.mu 写锁；返回是否有需要合成的任务
+func (s *HawkingScheduler) revoiceTaskLocked(sess *HawkingSession, task *models.HawkingTask, voiceType string) (events []*TaskStatusEventData, pending bool) {
+	task.VoiceType = voiceType
+	// 旧音色的音频作废（排队中/合成中的任务随旧批次一起被取消）
+	events = append(events, s.transitionLocked(sess, task, models.TaskSuperseded, "voice_changed"))
+
+	// 基于已锁定的 task.Text 计算哈希，不再重新生成文案
+	predictedName, _ := s.generateFileName(task, voiceType)
+	// 第一步：先看服务端磁盘到底有没有
+	if s.checkAudioExists(predictedName) {
+		// 只要服务端有，无论客户端传没传，都直接复用
+		task.AudioURL = fmt.Sprintf("/static/audio/%s.mp3", predictedName)
+		events = append(events, s.transitionLocked(sess, task, models.TaskReady, "cache_hit"))
+		log.Printf("♻️ 命中服务端缓存 [音色: %s]: %s", voiceType, predictedName)
+		return events, false
 	}
-	sess.mu.RUnlock()
 
-	for _, task := range tasks {
-		// 🌟 检查点 1: Context 是否被取消（音色是否又换了）
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
+	// 如果服务端磁盘没有：
+	// 无论客户端本地有没有，都必须重新合成，否则必然 404
+	task.AudioURL = ""
+	// 换了音色等于换了一次新请求，之前的失败记录清零
+	task.Attempts = 0
+	task.LastError = ""
+	task.NextRetryAt = nil
+	events = append(events, s.transitionLocked(sess, task, models.TaskQueued, ""))
+	log.Printf("⚡️ 无缓存，准备合成新音色 [%s]: %s", voiceType, predictedName)
+	return events, true
+}
 
-		product, err := s.productRepo.FindByID(task.ProductID)
-		if err != nil {
-			continue
-		}
+// SetTaskVoice 为单个任务固定音色（pinned=false 则取消固定，回到 Session 默认音色）
+// ref 为商品 ID 时作用于该商品的所有任务
+func (s *HawkingScheduler) SetTaskVoice(sessionID string, ref string, voiceType string, pinned bool) error {
+	s.sessionMu.RLock()
+	sess, exists := s.sessions[SessionKey(sessionID)]
+	s.sessionMu.RUnlock()
+	if !exists {
+		return fmt.Errorf("叫卖会话不存在")
+	}
 
-		// 执行合成，传入带取消功能的 ctx
-		audioURL, script, err := s.executeHawking(ctx, product, task)
-		if err != nil {
-			continue
-		}
+	sess.mu.Lock()
+	tasks := matchTasksLocked(sess, ref)
+	if len(tasks) == 0 {
+		sess.mu.Unlock()
+		return fmt.Errorf("任务不存在")
+	}
+	if !pinned {
+		voiceType = sess.VoiceType
+	}
 
-		sess.mu.Lock()
-		// 🌟 检查点 2: 双重校验版本号
-		if sess.VoiceVersion != version {
-			sess.mu.Unlock()
-			return // 版本不一致，说明切换了，直接丢弃本次合成结果
+	var events []*TaskStatusEventData
+	pending := false
+	for _, task := range tasks {
+		task.PinnedVoice = pinned
+		sess.touchLocked(task)
+		if task.VoiceType != voiceType || task.Status == models.TaskFailed {
+			taskEvents, taskPending := s.revoiceTaskLocked(sess, task, voiceType)
+			events = append(events, taskEvents...)
+			pending = pending || taskPending
 		}
+	}
+	sess.mu.Unlock()
+	s.persistSession(sess)
+	s.emitStatusEvents(events...)
+	sess.wakePlaylist()
 
-		task.IsSynthesized = true
-		task.AudioURL = audioURL
-		task.Text = script
+	if pending {
+		sess.notify()
+	}
+	return nil
+}
 
-		introPool := s.GetIntroPoolByVoice(sess.VoiceType)
-		s.broadcastPlayEventToSession(sess.ID, product, task, introPool)
-		sess.mu.Unlock()
+// introPoolForVoices 合并多个音色的开场白池，每个任务按自己的音色取开场白
+func (s *HawkingScheduler) introPoolForVoices(voices map[string]bool) []*models.HawkingIntro {
+	// 按音色排序，保证同样的内容每次顺序一致
+	names := make([]string, 0, len(voices))
+	for voice := range voices {
+		names = append(names, voice)
+	}
+	sort.Strings(names)
+
+	var introPool = make([]*models.HawkingIntro, 0)
+	for _, voice := range names {
+		introPool = append(introPool, s.GetIntroPoolByVoice(voice)...)
 	}
+	return introPool
 }
 
 func (s *HawkingScheduler) GetIntroPoolByVoice(voiceType string) []*models.HawkingIntro {
@@ -598,3 +1311,11 @@ func (s *HawkingScheduler) GetIntroPoolByVoice(voiceType string) []*models.Hawki
 	}
 	return introPool
 }
+
+// pickInt 请求值优先，未传（<=0）时回退到默认值
+func pickInt(value int, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,403 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// --- 测试用的假依赖 ---
+
+type fakeProductRepo struct {
+	repositories.ProductRepository // 用不到的方法留空，调用即 panic
+	products                       map[string]*models.Product
+
+	mu  sync.Mutex
+	err error // 不为空时 FindByID 返回该错误，模拟数据库故障
+}
+
+func (r *fakeProductRepo) setError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *fakeProductRepo) FindByID(id string) (*models.Product, error) {
+	r.mu.Lock()
+	err := r.err
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := r.products[strings.ToLower(id)]; ok {
+		copied := *p
+		return &copied, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeProductRepo) UpdateHawkingFields(id string, fields map[string]interface{}) error {
+	return nil
+}
+
+func (r *fakeProductRepo) UpdateHawkingStatus(id string, updates map[string]interface{}) error {
+	return nil
+}
+
+type fakeSessionRepo struct {
+	mu      sync.Mutex
+	records map[string]models.HawkingSessionRecord
+	saves   int
+}
+
+func (r *fakeSessionRepo) SaveSession(record *models.HawkingSessionRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saves++
+	r.records[record.ID] = *record
+	return nil
+}
+
+func (r *fakeSessionRepo) DeleteSession(sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.records, sessionID)
+	return nil
+}
+
+func (r *fakeSessionRepo) FindAll() ([]models.HawkingSessionRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := make([]models.HawkingSessionRecord, 0, len(r.records))
+	for _, record := range r.records {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (r *fakeSessionRepo) FindPendingTasks(productID string) ([]models.HawkingTaskRecord, error) {
+	return nil, nil
+}
+
+// fakeAudio 不真正合成，只记录被合成的文件名
+type fakeAudio struct {
+	calls chan string
+}
+
+func (a *fakeAudio) GenerateAudio(ctx context.Context, text string, identifier string, voiceType string) (string, error) {
+	a.calls <- identifier
+	return fmt.Sprintf("/static/audio/%s.mp3", identifier), nil
+}
+
+func (a *fakeAudio) GetRealVoiceID(voiceType string) string {
+	return voiceType
+}
+
+type testEnv struct {
+	scheduler *HawkingScheduler
+	products  *fakeProductRepo
+	sessions  *fakeSessionRepo
+	audio     *fakeAudio
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	hub := NewHub()
+	go hub.Run()
+	executor := NewSynthesisExecutor(1, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second, Timeout: time.Second})
+	executor.Start()
+
+	env := &testEnv{
+		products: &fakeProductRepo{products: make(map[string]*models.Product)},
+		sessions: &fakeSessionRepo{records: make(map[string]models.HawkingSessionRecord)},
+		audio:    &fakeAudio{calls: make(chan string, 100)},
+	}
+	env.scheduler = NewHawkingScheduler(env.products, repositories.NewMemIntroRepository(), env.sessions, env.audio, executor, hub)
+	return env
+}
+
+// addProduct 登记一个属于门店的商品
+func (e *testEnv) addProduct(storeID uuid.UUID, name string) *models.Product {
+	p := &models.Product{Base: models.Base{ID: uuid.New()}, StoreID: storeID, Name: name, Unit: "斤"}
+	e.products.products[p.ID.String()] = p
+	return p
+}
+
+func (e *testEnv) sessionCount() int {
+	e.scheduler.sessionMu.RLock()
+	defer e.scheduler.sessionMu.RUnlock()
+	return len(e.scheduler.sessions)
+}
+
+func TestSessionLookupIgnoresIDCase(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	pork := env.addProduct(storeID, "五花肉")
+
+	// 门店默认会话由 iOS 端用大写的门店 ID 创建
+	upper := strings.ToUpper(storeID.String())
+	env.scheduler.ApplyBatch(upper, BatchOp{
+		StoreID:   upper,
+		VoiceType: models.VoiceSoftGirl,
+		Add: []TaskSpec{{Product: pork, Req: models.AddTaskReq{
+			StoreID: upper, ProductID: pork.ID.String(), Text: "五花肉十二块一斤", Price: 12,
+		}}},
+	})
+
+	// 广播按 Announcement.StoreID.String()（小写）查会话音色
+	if voice := env.scheduler.SessionVoice(storeID.String()); voice != models.VoiceSoftGirl {
+		t.Errorf("SessionVoice=%q, want %q", voice, models.VoiceSoftGirl)
+	}
+	// 插播只应返回一个会话，且正在播放的会话被打断
+	sessionIDs := env.scheduler.PreemptPlaylists(storeID.String(), "", time.Second)
+	if len(sessionIDs) != 1 || sessionIDs[0] != storeID.String() {
+		t.Errorf("PreemptPlaylists=%v, want [%s]", sessionIDs, storeID)
+	}
+	env.scheduler.sessionMu.RLock()
+	sess := env.scheduler.sessions[storeID.String()]
+	env.scheduler.sessionMu.RUnlock()
+	if sess == nil {
+		t.Fatalf("找不到小写 ID 的会话")
+	}
+	sess.mu.RLock()
+	held := sess.holdUntil.After(time.Now())
+	sess.mu.RUnlock()
+	if !held {
+		t.Errorf("大写 ID 创建的会话没有被插播暂停")
+	}
+}
+
+func TestRestoreRetriesTaskInBackoff(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	pork := env.addProduct(storeID, "五花肉")
+
+	// 重启前合成失败，还在退避中
+	nextRetry := time.Now().Add(300 * time.Millisecond)
+	task := models.HawkingTask{
+		ID:          uuid.NewString(),
+		ProductID:   pork.ID.String(),
+		Text:        "五花肉十二块一斤",
+		VoiceType:   models.VoiceSunnyBoy,
+		Status:      models.TaskQueued,
+		Attempts:    1,
+		NextRetryAt: &nextRetry,
+	}
+	env.sessions.records[storeID.String()] = models.HawkingSessionRecord{
+		ID:        storeID.String(),
+		StoreID:   storeID.String(),
+		VoiceType: models.VoiceSunnyBoy,
+		Tasks:     []models.HawkingTaskRecord{{SessionID: storeID.String(), Task: task}},
+	}
+
+	if err := env.scheduler.Restore(); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	select {
+	case <-env.audio.calls:
+		if time.Now().Before(nextRetry) {
+			t.Errorf("退避还没结束就开始重试")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("恢复后退避中的任务一直没有重试")
+	}
+}
+
+// saveCount 落库次数
+func (r *fakeSessionRepo) saveCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.saves
+}
+
+// taskState 读取任务当前的状态和已尝试次数
+func (e *testEnv) taskState(sessionID string, taskID string) (models.TaskStatus, int) {
+	e.scheduler.sessionMu.RLock()
+	sess := e.scheduler.sessions[SessionKey(sessionID)]
+	e.scheduler.sessionMu.RUnlock()
+	if sess == nil {
+		return "", 0
+	}
+	sess.mu.RLock()
+	defer sess.mu.RUnlock()
+	for _, task := range sess.ActiveTasks {
+		if task.ID == taskID {
+			return task.Status, task.Attempts
+		}
+	}
+	return "", 0
+}
+
+func TestSynthesisRetriesTransientProductLookupError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus models.TaskStatus
+	}{
+		{"数据库临时故障按退避重试", errors.New("dial tcp: connection refused"), models.TaskQueued},
+		{"商品已删除直接失败", gorm.ErrRecordNotFound, models.TaskFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			storeID := uuid.New()
+			pork := env.addProduct(storeID, "五花肉")
+			env.products.setError(tt.err)
+
+			snapshot := env.scheduler.ApplyBatch(storeID.String(), BatchOp{
+				StoreID:   storeID.String(),
+				VoiceType: models.VoiceSunnyBoy,
+				Add: []TaskSpec{{Product: pork, Req: models.AddTaskReq{
+					StoreID: storeID.String(), ProductID: pork.ID.String(), Text: "五花肉十二块一斤", Price: 12,
+				}}},
+			})
+			taskID := snapshot.Products[0].ID
+
+			deadline := time.Now().Add(2 * time.Second)
+			for {
+				status, attempts := env.taskState(storeID.String(), taskID)
+				if attempts == 1 {
+					if status != tt.wantStatus {
+						t.Fatalf("第一次合成失败后 status = %s, want %s", status, tt.wantStatus)
+					}
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("任务一直没有开始合成: status=%s attempts=%d", status, attempts)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if tt.wantStatus != models.TaskQueued {
+				return
+			}
+
+			// 数据库恢复后，退避结束时自动重试成功
+			env.products.setError(nil)
+			select {
+			case <-env.audio.calls:
+			case <-time.After(3 * time.Second):
+				t.Fatalf("数据库恢复后任务没有重试")
+			}
+		})
+	}
+}
+
+func TestQueueModeWaitDoesNotHoldExecutorWorker(t *testing.T) {
+	env := newTestEnv(t)
+	env.scheduler.EnableSynthesisQueue()
+	storeID := uuid.New()
+
+	// 执行器只有 1 个 worker，两个商品都在等队列合成（文件一直不出现）
+	var specs []TaskSpec
+	for _, name := range []string{"五花肉", "土鸡蛋"} {
+		p := env.addProduct(storeID, name)
+		specs = append(specs, TaskSpec{Product: p, Req: models.AddTaskReq{
+			StoreID: storeID.String(), ProductID: p.ID.String(), Text: name + "特价", Price: 10,
+		}})
+	}
+	env.scheduler.ApplyBatch(storeID.String(), BatchOp{StoreID: storeID.String(), VoiceType: models.VoiceSunnyBoy, Add: specs})
+	time.Sleep(100 * time.Millisecond)
+
+	// 紧急广播仍然能马上拿到 worker
+	done := make(chan struct{})
+	env.scheduler.executor.Submit(&SynthesisJob{
+		SessionID: storeID.String(),
+		Urgent:    true,
+		Ctx:       context.Background(),
+		Run:       func(ctx context.Context) { close(done) },
+	})
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("等待队列合成占住了执行器的 worker，紧急工作没有执行")
+	}
+}
+
+// addTasks 为每个商品名各建一个商品，一次批量加进门店的默认会话
+func (e *testEnv) addTasks(storeID uuid.UUID, names ...string) *models.TasksSnapshotData {
+	var specs []TaskSpec
+	for _, name := range names {
+		specs = append(specs, e.spec(storeID, e.addProduct(storeID, name)))
+	}
+	return e.scheduler.ApplyBatch(storeID.String(), BatchOp{StoreID: storeID.String(), VoiceType: models.VoiceSunnyBoy, Add: specs})
+}
+
+// spec 构造添加商品任务的一项
+func (e *testEnv) spec(storeID uuid.UUID, p *models.Product) TaskSpec {
+	return TaskSpec{Product: p, Req: models.AddTaskReq{
+		StoreID: storeID.String(), ProductID: p.ID.String(), Text: p.Name + "特价", Price: 10,
+	}}
+}
+
+// waitReady 等会话里的任务都合成完，之后修订号只会因为测试里的操作变化
+func (e *testEnv) waitReady(t *testing.T, sessionID string) *models.TasksSnapshotData {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		snapshot := e.scheduler.GetActiveTasksSnapshot(sessionID)
+		ready := true
+		for _, task := range snapshot.Products {
+			if task.Status != models.TaskReady {
+				ready = false
+			}
+		}
+		if ready {
+			return snapshot
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务一直没有合成完")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// taskIDs 按快照顺序取出任务 ID
+func taskIDs(snapshot *models.TasksSnapshotData) []string {
+	ids := make([]string, 0, len(snapshot.Products))
+	for _, task := range snapshot.Products {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+// subscribe 注册一个没有真实连接的客户端，用来接收房间里的广播
+func subscribe(hub *Hub, rooms ...string) *Client {
+	client := NewClient(hub, nil, rooms, ClientIdentity{})
+	hub.Register <- client
+	return client
+}
+
+// waitMessage 等待客户端收到指定类型的消息，返回消息体；超时返回 nil
+func waitMessage(client *Client, msgType string, timeout time.Duration) json.RawMessage {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case raw, ok := <-client.Send:
+			if !ok {
+				return nil
+			}
+			var msg struct {
+				Type string          `json:"type"`
+				Data json.RawMessage `json:"data"`
+			}
+			if json.Unmarshal(raw, &msg) == nil && msg.Type == msgType {
+				return msg.Data
+			}
+		case <-timer.C:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"fmt"
+	"hawker-backend/models"
+	"log"
+	"time"
+)
+
+// 会话的播放状态，通过 HAWKING_SESSION_STATE 下发
+const (
+	SessionPlaying = "playing"
+	SessionPaused  = "paused"
+	SessionStopped = "stopped" // 会话已销毁，任务全部移除
+)
+
+// SessionStateEventData HAWKING_SESSION_STATE 消息体：同一会话的音箱据此一起停止或开始播放
+type SessionStateEventData struct {
+	SessionID string    `json:"session_id"`
+	State     string    `json:"state"`
+	At        time.Time `json:"at"`
+}
+
+// PauseSession 暂停会话：任务、音频都保留，合成照常进行，只是不再下发 HAWKING_NEXT
+func (s *HawkingScheduler) PauseSession(sessionID string) error {
+	return s.setPaused(sessionID, true)
+}
+
+// ResumeSession 恢复暂停的会话，轮播立即继续
+func (s *HawkingScheduler) ResumeSession(sessionID string) error {
+	return s.setPaused(sessionID, false)
+}
+
+func (s *HawkingScheduler) setPaused(sessionID string, paused bool) error {
+	sessionID = SessionKey(sessionID)
+	s.sessionMu.RLock()
+	sess, exists := s.sessions[sessionID]
+	s.sessionMu.RUnlock()
+	if !exists {
+		return fmt.Errorf("当前没有进行中的叫卖会话")
+	}
+
+	sess.mu.Lock()
+	changed := sess.Paused != paused
+	sess.Paused = paused
+	if changed {
+		sess.touchLocked(nil)
+	}
+	sess.mu.Unlock()
+
+	state := SessionPlaying
+	if paused {
+		state = SessionPaused
+	}
+	// 重复操作不改状态，但照样广播一次，让错过消息的音箱对齐
+	if changed {
+		s.persistSession(sess)
+		if paused {
+			sess.interruptPlaylist() // 正在播的这条不再等它播完
+		} else {
+			sess.wakePlaylist()
+		}
+		log.Printf("⏯️ Session [%s] 状态切换为 %s", sessionID, state)
+	}
+	s.broadcastSessionState(sessionID, state)
+	return nil
+}
+
+// StopSession 停止会话：移除所有任务并销毁会话，与清空任务等价
+func (s *HawkingScheduler) StopSession(sessionID string) *models.TasksSnapshotData {
+	sessionID = SessionKey(sessionID)
+	snapshot := s.ApplyBatch(sessionID, BatchOp{ReplaceAll: true})
+	s.broadcastSessionState(sessionID, SessionStopped)
+	log.Printf("⏹️ Session [%s] 已停止", sessionID)
+	return snapshot
+}
+
+func (s *HawkingScheduler) broadcastSessionState(sessionID string, state string) {
+	s.broadcastToSession(sessionID, models.WSMessage{
+		Type: "HAWKING_SESSION_STATE",
+		Data: SessionStateEventData{SessionID: sessionID, State: state, At: time.Now()},
+	})
+}
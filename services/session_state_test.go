@@ -0,0 +1,74 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPauseAndResumeSession(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	client := subscribe(env.scheduler.Hub, SessionRoom(sessionID))
+	env.waitReady(t, env.addTasks(storeID, "五花肉").SessionID)
+	if waitMessage(client, "HAWKING_NEXT", 2*time.Second) == nil {
+		t.Fatalf("任务合成完后没有开始轮播")
+	}
+
+	if err := env.scheduler.PauseSession(sessionID); err != nil {
+		t.Fatalf("PauseSession: %v", err)
+	}
+	var state SessionStateEventData
+	if data := waitMessage(client, "HAWKING_SESSION_STATE", time.Second); data == nil || json.Unmarshal(data, &state) != nil || state.State != SessionPaused {
+		t.Fatalf("暂停后没有广播 paused: %s", data)
+	}
+	// 暂停会打断正在等待的条目，但不再下发新的条目；任务保留，状态落库
+	if waitMessage(client, "HAWKING_NEXT", 300*time.Millisecond) != nil {
+		t.Errorf("暂停后仍在下发 HAWKING_NEXT")
+	}
+	snapshot := env.scheduler.GetActiveTasksSnapshot(sessionID)
+	if !snapshot.Paused || len(snapshot.Products) != 1 {
+		t.Errorf("暂停后快照 paused=%v 任务数=%d", snapshot.Paused, len(snapshot.Products))
+	}
+	if records, _ := env.sessions.FindAll(); len(records) != 1 || !records[0].Paused {
+		t.Errorf("暂停状态没有落库: %+v", records)
+	}
+
+	// 恢复后立即继续轮播
+	if err := env.scheduler.ResumeSession(sessionID); err != nil {
+		t.Fatalf("ResumeSession: %v", err)
+	}
+	if waitMessage(client, "HAWKING_NEXT", time.Second) == nil {
+		t.Errorf("恢复后没有继续轮播")
+	}
+	if env.scheduler.GetActiveTasksSnapshot(sessionID).Paused {
+		t.Errorf("恢复后快照仍是暂停")
+	}
+}
+
+func TestPauseUnknownSession(t *testing.T) {
+	env := newTestEnv(t)
+	if err := env.scheduler.PauseSession(uuid.NewString()); err == nil {
+		t.Errorf("会话不存在时暂停没有报错")
+	}
+}
+
+func TestStopSession(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	client := subscribe(env.scheduler.Hub, SessionRoom(sessionID))
+	env.addTasks(storeID, "五花肉", "土鸡蛋")
+
+	snapshot := env.scheduler.StopSession(sessionID)
+	if len(snapshot.Products) != 0 || env.scheduler.HasSession(sessionID) {
+		t.Errorf("停止后会话仍然存在: %+v", snapshot.Products)
+	}
+	var state SessionStateEventData
+	if data := waitMessage(client, "HAWKING_SESSION_STATE", time.Second); data == nil || json.Unmarshal(data, &state) != nil || state.State != SessionStopped {
+		t.Errorf("停止后没有广播 stopped: %s", data)
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"hawker-backend/logic"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// StockRuleEngine 监听库存同步，按阈值自动切换商品的叫卖模式
+// 模式变化的商品如果正在叫卖，会重新生成文案并重新合成
+type StockRuleEngine struct {
+	rule        logic.StockRule
+	productRepo repositories.ProductRepository
+	scheduler   *HawkingScheduler
+}
+
+func NewStockRuleEngine(rule logic.StockRule, productRepo repositories.ProductRepository, scheduler *HawkingScheduler) *StockRuleEngine {
+	return &StockRuleEngine{rule: rule, productRepo: productRepo, scheduler: scheduler}
+}
+
+// OnProductsSynced 在 SyncProducts 落库之后调用，返回模式发生变化的商品数量
+func (e *StockRuleEngine) OnProductsSynced(productIDs []uuid.UUID) int {
+	changed := 0
+	for _, id := range productIDs {
+		product, err := e.productRepo.FindByID(id.String())
+		if err != nil {
+			continue
+		}
+
+		mode, ok := e.rule.Evaluate(*product)
+		if !ok || mode == product.HawkingMode {
+			continue
+		}
+		if err := e.productRepo.UpdateHawkingFields(product.ID.String(), map[string]interface{}{"hawking_mode": mode}); err != nil {
+			log.Printf("❌ 更新叫卖模式失败 [%s]: %v", product.Name, err)
+			continue
+		}
+		log.Printf("📦 库存 %d/%d，[%s] 叫卖模式 %d -> %d", product.CurrentStock, product.SafetyStock, product.Name, product.HawkingMode, mode)
+
+		product.HawkingMode = mode
+		changed++
+		e.scheduler.RefreshProductScript(product, "stock_mode_changed")
+	}
+	return changed
+}
+
+// RefreshProductScript 商品信息变化后，为所有会话中该商品的自动文案任务重新生成文案并重新合成
+// 店主手写的文案、复读机模式的文案不受商品模式影响，保持不动
+func (s *HawkingScheduler) RefreshProductScript(product *models.Product, reason string) {
+	productID := product.ID.String()
+
+	s.sessionMu.RLock()
+	sessions := make([]*HawkingSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionMu.RUnlock()
+
+	for _, sess := range sessions {
+		sess.mu.Lock()
+		var events []*TaskStatusEventData
+		refreshed := 0
+		for _, task := range sess.ActiveTasks {
+			if !strings.EqualFold(task.ProductID, productID) || task.CustomText != "" || task.UseRepeatMode {
+				continue
+			}
+			task.Text = logic.GenerateScript(*product, task)
+			events = append(events, s.requeueTaskLocked(sess, task, reason))
+			refreshed++
+		}
+		sess.mu.Unlock()
+		if refreshed == 0 {
+			continue
+		}
+
+		s.persistSession(sess)
+		s.emitStatusEvents(events...)
+		sess.notify()
+		log.Printf("📝 Session [%s] 已重新生成 %d 条文案: %s", sess.ID, refreshed, product.Name)
+	}
+}
+
+// requeueTaskLocked 文案变化后让任务重新合成，排队中的任务直接沿用新文案
+// 调用方必须持有 sess.mu 写锁
+func (s *HawkingScheduler) requeueTaskLocked(sess *HawkingSession, task *models.HawkingTask, reason string) *TaskStatusEventData {
+	sess.touchLocked(task) // 文案、价格已变化，排队中的任务不会跳转状态，这里单独记一次
+	task.Attempts = 0
+	task.LastError = ""
+	task.NextRetryAt = nil
+	if task.Status == models.TaskQueued {
+		return nil
+	}
+	task.AudioURL = ""
+	return s.transitionLocked(sess, task, models.TaskQueued, reason)
+}
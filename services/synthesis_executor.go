@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// SynthesisJob 提交给合成执行器的一项工作
+type SynthesisJob struct {
+	SessionID string          // 所属 Session，用于跨 Session 公平调度
+	Urgent    bool            // 紧急工作（如临时广播）插队到所有 Session 之前
+	Ctx       context.Context // 排队期间被取消时，Run 应尽快返回
+	Run       func(ctx context.Context)
+}
+
+// RetryPolicy 合成失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最多尝试次数（含第一次）
+	BaseDelay   time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxDelay    time.Duration // 等待时间上限
+	Timeout     time.Duration // 单次合成的超时时间
+}
+
+// Backoff 第 attempt 次失败后，距离下一次重试需要等待的时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// SynthesisExecutor 全局共享的合成执行器
+// - 固定数量的 worker，限制同时在途的 TTS 请求数
+// - 每个 Session 一个 FIFO 队列，worker 在 Session 之间轮转取任务，避免大门店饿死小门店
+type SynthesisExecutor struct {
+	workers int
+	Policy  RetryPolicy
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[string][]*SynthesisJob // 每个 Session 的待执行队列
+	ring   []string                   // 有待执行任务的 Session，按轮转顺序排列
+	urgent []*SynthesisJob            // 紧急工作，优先于所有 Session 队列
+}
+
+func NewSynthesisExecutor(workers int, policy RetryPolicy) *SynthesisExecutor {
+	if workers <= 0 {
+		workers = 1
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = 30 * time.Second
+	}
+	e := &SynthesisExecutor{
+		workers: workers,
+		Policy:  policy,
+		queues:  make(map[string][]*SynthesisJob),
+	}
+	e.cond = sync.NewCond(&e.mu)
+	return e
+}
+
+// Start 启动所有 worker
+func (e *SynthesisExecutor) Start() {
+	for i := 0; i < e.workers; i++ {
+		go e.runWorker()
+	}
+	log.Printf("🏭 合成执行器已启动，并发数: %d", e.workers)
+}
+
+// Submit 提交一项合成工作，不会阻塞
+func (e *SynthesisExecutor) Submit(job *SynthesisJob) {
+	e.mu.Lock()
+	if job.Urgent {
+		e.urgent = append(e.urgent, job)
+		e.mu.Unlock()
+		e.cond.Signal()
+		return
+	}
+	if len(e.queues[job.SessionID]) == 0 {
+		e.ring = append(e.ring, job.SessionID)
+	}
+	e.queues[job.SessionID] = append(e.queues[job.SessionID], job)
+	e.mu.Unlock()
+	e.cond.Signal()
+}
+
+// take 轮转取出下一项工作，队列为空时阻塞
+func (e *SynthesisExecutor) take() *SynthesisJob {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for len(e.ring) == 0 && len(e.urgent) == 0 {
+		e.cond.Wait()
+	}
+
+	if len(e.urgent) > 0 {
+		job := e.urgent[0]
+		e.urgent = e.urgent[1:]
+		return job
+	}
+
+	// 取队头 Session 的第一项工作，如果它还有剩余，就排到队尾
+	sessionID := e.ring[0]
+	e.ring = e.ring[1:]
+	queue := e.queues[sessionID]
+	job := queue[0]
+	if len(queue) > 1 {
+		e.queues[sessionID] = queue[1:]
+		e.ring = append(e.ring, sessionID)
+	} else {
+		delete(e.queues, sessionID)
+	}
+	return job
+}
+
+func (e *SynthesisExecutor) runWorker() {
+	for {
+		job := e.take()
+		// 即使 ctx 已取消也交给 Run 处理，由它负责收尾（清理在途标记等）
+		job.Run(job.Ctx)
+	}
+}
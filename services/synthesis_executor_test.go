@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSynthesisExecutorTakesRoundRobin(t *testing.T) {
+	// 不启动 worker，直接看 take 的出队顺序
+	e := NewSynthesisExecutor(1, RetryPolicy{})
+	names := make(map[*SynthesisJob]string)
+	submit := func(name string, sessionID string, urgent bool) {
+		job := &SynthesisJob{SessionID: sessionID, Urgent: urgent, Ctx: context.Background(), Run: func(ctx context.Context) {}}
+		names[job] = name
+		e.Submit(job)
+	}
+	submit("大门店-1", "big", false)
+	submit("大门店-2", "big", false)
+	submit("大门店-3", "big", false)
+	submit("小门店-1", "small", false)
+	submit("广播", "small", true)
+
+	var got []string
+	for range names {
+		got = append(got, names[e.take()])
+	}
+	want := []string{"广播", "大门店-1", "小门店-1", "大门店-2", "大门店-3"}
+	if !slices.Equal(got, want) {
+		t.Errorf("出队顺序 = %v, want %v", got, want)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hawker-backend/models"
+	"hawker-backend/repositories"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 等待队列合成时检查音频文件是否已生成的间隔
+	queueFileCheck = 500 * time.Millisecond
+	// 等待期间重新投递的间隔：worker 可能刚好在投递前清掉了标记
+	queueRequeue = 5 * time.Second
+)
+
+// EnableSynthesisQueue 切换到队列合成模式：本实例不再直接调用 TTS，而是把商品投递到数据库队列，
+// 由任意实例的 QueueWorker 合成到共享的 static 目录，本实例等到文件出现即视为合成完成
+// 任务的状态机、重试和失败处理保持不变
+func (s *HawkingScheduler) EnableSynthesisQueue() {
+	s.queueMode = true
+}
+
+// enqueueProduct 标记商品有待合成的任务，worker 按商品 Priority 领取
+func (s *HawkingScheduler) enqueueProduct(productID string) {
+	if err := s.productRepo.UpdateHawkingFields(productID, map[string]interface{}{"is_hawking": true}); err != nil {
+		log.Printf("❌ 投递合成队列失败 [%s]: %v", productID, err)
+	}
+}
+
+// waitQueuedAudio 投递到合成队列并等待音频文件生成，超时由 ctx 控制（超时按普通失败走重试）
+// 在任务自己的协程里等待，不占用合成执行器的 worker（见 runSynthesisBatch）
+func (s *HawkingScheduler) waitQueuedAudio(ctx context.Context, productID string, fileName string) (string, error) {
+	s.enqueueProduct(productID)
+
+	check := time.NewTicker(queueFileCheck)
+	defer check.Stop()
+	lastEnqueue := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case now := <-check.C:
+			if s.checkAudioExists(fileName) {
+				return fmt.Sprintf("/static/audio/%s.mp3", fileName), nil
+			}
+			if now.Sub(lastEnqueue) >= queueRequeue {
+				s.enqueueProduct(productID)
+				lastEnqueue = now
+			}
+		}
+	}
+}
+
+// QueueWorker 合成队列消费者：从数据库领取商品，把所有会话（包括其它实例的会话）中
+// 该商品还没合成的文案合成到共享 static 目录
+// 领取依赖 FOR UPDATE SKIP LOCKED，多个实例同时消费也不会重复合成；
+// worker 崩溃后租约（locked_at）超时，商品会被其它 worker 重新领取
+type QueueWorker struct {
+	productRepo repositories.ProductRepository
+	sessionRepo repositories.HawkingSessionRepository
+	audio       AudioService
+	timeout     time.Duration // 单次合成的超时
+	poll        time.Duration // 队列为空时的轮询间隔
+}
+
+func NewQueueWorker(productRepo repositories.ProductRepository, sessionRepo repositories.HawkingSessionRepository, audio AudioService, timeout time.Duration, poll time.Duration) *QueueWorker {
+	return &QueueWorker{
+		productRepo: productRepo,
+		sessionRepo: sessionRepo,
+		audio:       audio,
+		timeout:     timeout,
+		poll:        poll,
+	}
+}
+
+// Start 启动 n 个消费协程
+func (w *QueueWorker) Start(n int) {
+	for i := 0; i < n; i++ {
+		go w.run(i)
+	}
+	log.Printf("📬 合成队列 worker 已启动: %d 个", n)
+}
+
+func (w *QueueWorker) run(id int) {
+	for {
+		product, err := w.productRepo.GetNextHawkingProduct()
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("❌ worker %d 领取合成队列失败: %v", id, err)
+			}
+			time.Sleep(w.poll)
+			continue
+		}
+		w.process(product)
+	}
+}
+
+// process 合成商品所有待合成的文案，完成后清除标记并归还租约
+// 失败的文案不在这里重试：发起方等待超时后会按自己的重试策略重新投递
+func (w *QueueWorker) process(product *models.Product) {
+	productID := product.ID.String()
+	updates := map[string]interface{}{"is_hawking": false}
+	defer func() {
+		if err := w.productRepo.UpdateHawkingStatus(productID, updates); err != nil {
+			log.Printf("❌ 归还合成队列租约失败 [%s]: %v", product.Name, err)
+		}
+	}()
+
+	records, err := w.sessionRepo.FindPendingTasks(productID)
+	if err != nil {
+		log.Printf("❌ 查询待合成任务失败 [%s]: %v", product.Name, err)
+		return
+	}
+
+	done := make(map[string]bool)
+	for i := range records {
+		task := &records[i].Task
+		fileName, hash := audioFileName(task, task.VoiceType)
+		if done[fileName] || audioExists(fileName) {
+			continue
+		}
+		done[fileName] = true
+
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		_, err := w.audio.GenerateAudio(ctx, task.Text, fileName, task.VoiceType)
+		cancel()
+		if err != nil {
+			log.Printf("❌ 队列合成失败 [%s]: %v", product.Name, err)
+			continue
+		}
+		updates["last_script_hash"] = hash
+		log.Printf("✅ 队列合成完成 [%s]: %s", product.Name, fileName)
+
+		// 续租：一个商品的文案较多时，避免合成途中租约过期被其它 worker 重复领取
+		if err := w.productRepo.UpdateHawkingFields(productID, map[string]interface{}{"locked_at": time.Now()}); err != nil {
+			log.Printf("⚠️ 合成队列续租失败 [%s]: %v", product.Name, err)
+		}
+	}
+}
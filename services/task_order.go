@@ -0,0 +1,150 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// sortedTasksLocked 按 Position 返回 Session 中的任务，调用方必须持有 sess.mu 读锁
+func sortedTasksLocked(sess *HawkingSession) []*models.HawkingTask {
+	tasks := make([]*models.HawkingTask, 0, len(sess.ActiveTasks))
+	for _, task := range sess.ActiveTasks {
+		tasks = append(tasks, task)
+	}
+	sortTasks(tasks)
+	return tasks
+}
+
+// sortTasks 按 Position 排序，位置相同（旧数据）时依次按促销明细顺序、任务 ID 排，保证结果稳定
+func sortTasks(tasks []*models.HawkingTask) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Position != tasks[j].Position {
+			return tasks[i].Position < tasks[j].Position
+		}
+		if tasks[i].SortOrder != tasks[j].SortOrder {
+			return tasks[i].SortOrder < tasks[j].SortOrder
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// renumberTasksLocked 把任务位置重新编号为 0..n-1，调用方必须持有 sess.mu 写锁
+func renumberTasksLocked(sess *HawkingSession) {
+	for i, task := range sortedTasksLocked(sess) {
+		task.Position = i
+	}
+}
+
+// nextPositionLocked 新任务排在最后，调用方必须持有 sess.mu 读锁
+func nextPositionLocked(sess *HawkingSession) int {
+	next := 0
+	for _, task := range sess.ActiveTasks {
+		if task.Position >= next {
+			next = task.Position + 1
+		}
+	}
+	return next
+}
+
+// matchTasksLocked 按任务 ID 查找；找不到时把 ref 当作商品 ID，返回该商品的所有任务
+// 兼容只认识商品 ID 的旧版客户端，调用方必须持有 sess.mu 读锁
+func matchTasksLocked(sess *HawkingSession, ref string) []*models.HawkingTask {
+	if task, ok := sess.ActiveTasks[strings.ToLower(ref)]; ok {
+		return []*models.HawkingTask{task}
+	}
+	var tasks []*models.HawkingTask
+	for _, task := range sortedTasksLocked(sess) {
+		if strings.EqualFold(task.ProductID, ref) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// replacedTaskLocked 找出新任务要替换的旧任务：
+//   - 指定了 task_id 的，修改的就是该任务
+//   - 促销、节目重新导入时，同一来源的同一商品视为替换
+//
+// 其余情况都是新增，同一商品可以同时有多个任务；调用方必须持有 sess.mu 读锁
+func replacedTaskLocked(sess *HawkingSession, task *models.HawkingTask) *models.HawkingTask {
+	if task.ID != "" {
+		return sess.ActiveTasks[task.ID]
+	}
+	if task.PromotionID == "" && task.ProgramID == "" {
+		return nil
+	}
+	for _, old := range sess.ActiveTasks {
+		if strings.EqualFold(old.ProductID, task.ProductID) &&
+			strings.EqualFold(old.PromotionID, task.PromotionID) &&
+			strings.EqualFold(old.ProgramID, task.ProgramID) {
+			return old
+		}
+	}
+	return nil
+}
+
+// assignTaskIDLocked 为新任务分配 ID 和位置，替换旧任务时沿用旧任务的 ID 和位置
+// 客户端带来的 ID 在会话中已不存在时（如整体替换先清空了会话）照常沿用，排在最后
+// 调用方必须持有 sess.mu 读锁
+func assignTaskIDLocked(sess *HawkingSession, task *models.HawkingTask, old *models.HawkingTask) {
+	if old != nil {
+		task.ID = old.ID
+		task.Position = old.Position
+		return
+	}
+	if task.ID == "" {
+		task.ID = uuid.NewString()
+	}
+	task.Position = nextPositionLocked(sess)
+}
+
+// setPositionLocked 位置有变化才推进修订号，调用方必须持有 sess.mu 写锁
+func setPositionLocked(sess *HawkingSession, task *models.HawkingTask, position int) {
+	if task.Position != position {
+		task.Position = position
+		sess.touchLocked(task)
+	}
+}
+
+// ReorderTasks 按客户端拖动后的顺序重排任务，返回最新快照
+// taskIDs 中不存在的 ID 会被忽略，未列出的任务保持原有相对顺序排在后面
+func (s *HawkingScheduler) ReorderTasks(sessionID string, taskIDs []string) *models.TasksSnapshotData {
+	s.sessionMu.RLock()
+	sess, exists := s.sessions[SessionKey(sessionID)]
+	s.sessionMu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	sess.mu.Lock()
+	listed := make(map[string]bool, len(taskIDs))
+	position := 0
+	for _, id := range taskIDs {
+		key := strings.ToLower(id)
+		task, ok := sess.ActiveTasks[key]
+		if !ok || listed[key] {
+			continue
+		}
+		listed[key] = true
+		setPositionLocked(sess, task, position)
+		position++
+	}
+	for _, task := range sortedTasksLocked(sess) {
+		if !listed[task.ID] {
+			setPositionLocked(sess, task, position)
+			position++
+		}
+	}
+	sess.mu.Unlock()
+
+	s.persistSession(sess)
+	log.Printf("🔀 Session [%s] 任务顺序已调整", sessionID)
+
+	snapshot := s.GetActiveTasksSnapshot(sessionID)
+	s.broadcastSnapshot(snapshot)
+	return snapshot
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReorderTasks(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	before := env.waitReady(t, env.addTasks(storeID, "五花肉", "土鸡蛋", "草鱼", "青菜").SessionID)
+	a, b, c, d := before.Products[0].ID, before.Products[1].ID, before.Products[2].ID, before.Products[3].ID
+
+	// 只列出部分任务（大写 ID、重复、不存在的 ID 混在里面）：列出的排前面，其余保持原有相对顺序
+	snapshot := env.scheduler.ReorderTasks(sessionID, []string{strings.ToUpper(c), uuid.NewString(), a, c})
+	if got, want := taskIDs(snapshot), []string{c, a, b, d}; !slices.Equal(got, want) {
+		t.Fatalf("重排后的顺序 = %v, want %v", got, want)
+	}
+	for i, task := range snapshot.Products {
+		if task.Position != i {
+			t.Errorf("任务 %s 的位置 = %d, want %d", task.ID, task.Position, i)
+		}
+	}
+
+	// 只有位置变了的任务推进修订号，d 的位置没变，增量同步不必下发
+	delta := env.scheduler.GetTasksDelta(sessionID, before.Epoch, before.Revision, "")
+	changed := make([]string, 0, len(delta.Changed))
+	for _, task := range delta.Changed {
+		changed = append(changed, task.ID)
+	}
+	if got, want := changed, []string{c, a, b}; delta.Full || !slices.Equal(got, want) {
+		t.Errorf("重排后的增量 full=%v changed=%v, want %v", delta.Full, got, want)
+	}
+
+	// 顺序没变时修订号不动
+	again := env.scheduler.ReorderTasks(sessionID, []string{c, a, b, d})
+	if again.Revision != snapshot.Revision {
+		t.Errorf("顺序不变修订号从 %d 变成了 %d", snapshot.Revision, again.Revision)
+	}
+}
+
+func TestReorderTasksUnknownSession(t *testing.T) {
+	env := newTestEnv(t)
+	if snapshot := env.scheduler.ReorderTasks(uuid.NewString(), nil); snapshot != nil {
+		t.Errorf("会话不存在时返回了快照: %+v", snapshot)
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hawker-backend/models"
+	"sort"
+	"strings"
+)
+
+// 每个 Session 最多保留的删除记录，超出后更早的增量请求只能拿全量
+const maxTombstones = 256
+
+// touchLocked 任务或会话有变化时推进修订号，task 为 nil 表示会话级别的变化（暂停、默认音色）
+// 注意：轮播引擎更新 LastPlayedAt 不算变化，否则每播一条都会产生增量
+// 调用方必须持有 sess.mu 写锁
+func (sess *HawkingSession) touchLocked(task *models.HawkingTask) {
+	sess.Revision++
+	if task != nil {
+		task.Revision = sess.Revision
+	}
+}
+
+// removeTaskLocked 作废并移除任务，留下删除记录供增量同步
+// 调用方必须持有 sess.mu 写锁，并在释放锁之后把返回的事件交给 emitStatusEvents
+func (s *HawkingScheduler) removeTaskLocked(sess *HawkingSession, task *models.HawkingTask, reason string) *TaskStatusEventData {
+	event := s.transitionLocked(sess, task, models.TaskCancelled, reason)
+	delete(sess.ActiveTasks, taskKey(task))
+	sess.touchLocked(nil)
+	sess.tombstones[task.ID] = sess.Revision
+
+	// 删除记录太多时丢掉较早的一半，并抬高增量同步的下限
+	if len(sess.tombstones) > maxTombstones {
+		revisions := make([]int64, 0, len(sess.tombstones))
+		for _, rev := range sess.tombstones {
+			revisions = append(revisions, rev)
+		}
+		sort.Slice(revisions, func(i, j int) bool { return revisions[i] < revisions[j] })
+		floor := revisions[len(revisions)/2]
+		for id, rev := range sess.tombstones {
+			if rev <= floor {
+				delete(sess.tombstones, id)
+			}
+		}
+		sess.deltaFloor = floor
+	}
+	return event
+}
+
+// GetTasksDelta 返回 since 之后的变化；epoch 与当前会话不一致（会话重建或服务重启前的删除记录已丢失）
+// 或 since 早于删除记录的下限时，退化为全量
+// introVersion 为客户端持有的开场白池版本，一致时不再下发开场白池
+func (s *HawkingScheduler) GetTasksDelta(sessionID string, epoch string, since int64, introVersion string) *models.TasksDeltaData {
+	s.sessionMu.RLock()
+	sess, exists := s.sessions[SessionKey(sessionID)]
+	s.sessionMu.RUnlock()
+
+	if !exists {
+		// 会话已销毁：全量就是空列表
+		return &models.TasksDeltaData{SessionID: SessionKey(sessionID), Since: since, Full: true, Changed: []*models.HawkingTask{}, Removed: []string{}}
+	}
+
+	sess.mu.RLock()
+	delta := &models.TasksDeltaData{
+		SessionID: sess.ID,
+		Epoch:     sess.Epoch,
+		Since:     since,
+		Revision:  sess.Revision,
+		Paused:    sess.Paused,
+		Changed:   make([]*models.HawkingTask, 0),
+		Removed:   make([]string, 0),
+	}
+	delta.Full = !strings.EqualFold(epoch, sess.Epoch) || since < sess.deltaFloor || since > sess.Revision
+
+	voices := map[string]bool{sess.VoiceType: true}
+	for _, task := range sortedTasksLocked(sess) {
+		voices[task.VoiceType] = true
+		if delta.Full || task.Revision > since {
+			snapshot := *task
+			delta.Changed = append(delta.Changed, &snapshot)
+		}
+	}
+	if !delta.Full {
+		for id, rev := range sess.tombstones {
+			if rev > since {
+				delta.Removed = append(delta.Removed, id)
+			}
+		}
+	}
+	sess.mu.RUnlock()
+
+	pool := s.introPoolForVoices(voices)
+	delta.IntroPoolVersion = introPoolVersion(pool)
+	if delta.IntroPoolVersion != introVersion {
+		delta.IntroPool = pool
+	}
+	return delta
+}
+
+// introPoolVersion 开场白池的内容摘要，客户端据此判断是否需要更新本地的开场白池
+func introPoolVersion(pool []*models.HawkingIntro) string {
+	keys := make([]string, 0, len(pool))
+	for _, intro := range pool {
+		keys = append(keys, intro.VoiceType+"|"+intro.IntroID+"|"+intro.AudioURL)
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(keys, "\n"))))[:8]
+}
+
+// introPoolForPlayLocked 播放事件只在该音色的开场白池有变化（或第一次下发）时才携带开场白池
+// 调用方必须持有 sess.mu 写锁
+func (sess *HawkingSession) introPoolForPlayLocked(voiceType string, pool []*models.HawkingIntro) ([]*models.HawkingIntro, string) {
+	version := introPoolVersion(pool)
+	if sess.sentIntro[voiceType] == version {
+		return nil, version
+	}
+	sess.sentIntro[voiceType] = version
+	return pool, version
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGetTasksDelta(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	before := env.waitReady(t, env.addTasks(storeID, "五花肉", "土鸡蛋").SessionID)
+	kept, dropped := before.Products[0].ID, before.Products[1].ID
+
+	// 移除一个、新加一个
+	env.scheduler.ApplyBatch(sessionID, BatchOp{StoreID: sessionID, Remove: []string{dropped}})
+	added := env.waitReady(t, env.addTasks(storeID, "草鱼").SessionID)
+	addedID := added.Products[1].ID
+
+	tests := []struct {
+		name        string
+		epoch       string
+		since       int64
+		wantFull    bool
+		wantChanged []string
+		wantRemoved []string
+	}{
+		{"增量只含变化和删除", before.Epoch, before.Revision, false, []string{addedID}, []string{dropped}},
+		{"已是最新", before.Epoch, added.Revision, false, []string{}, []string{}},
+		{"会话重建过", uuid.NewString(), before.Revision, true, []string{kept, addedID}, []string{}},
+		{"修订号比服务端新", before.Epoch, added.Revision + 1, true, []string{kept, addedID}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := env.scheduler.GetTasksDelta(sessionID, tt.epoch, tt.since, "")
+			changed := make([]string, 0, len(delta.Changed))
+			for _, task := range delta.Changed {
+				changed = append(changed, task.ID)
+			}
+			if delta.Full != tt.wantFull || !slices.Equal(changed, tt.wantChanged) || !slices.Equal(delta.Removed, tt.wantRemoved) {
+				t.Errorf("full=%v changed=%v removed=%v, want full=%v changed=%v removed=%v",
+					delta.Full, changed, delta.Removed, tt.wantFull, tt.wantChanged, tt.wantRemoved)
+			}
+			if delta.Revision != added.Revision {
+				t.Errorf("revision = %d, want %d", delta.Revision, added.Revision)
+			}
+		})
+	}
+}
+
+func TestGetTasksDeltaAfterTombstonesTrimmed(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	sessionID := storeID.String()
+	go func() {
+		for range env.audio.calls { // 任务比 fakeAudio 的缓冲多
+		}
+	}()
+	names := make([]string, maxTombstones+2)
+	for i := range names {
+		names[i] = fmt.Sprintf("商品%d", i)
+	}
+	before := env.waitReady(t, env.addTasks(storeID, names...).SessionID)
+
+	// 删除记录超过上限后，早于下限的增量请求只能拿全量
+	ids := taskIDs(before)
+	env.scheduler.ApplyBatch(sessionID, BatchOp{StoreID: sessionID, Remove: ids[1:]})
+	delta := env.scheduler.GetTasksDelta(sessionID, before.Epoch, before.Revision, "")
+	if !delta.Full || len(delta.Changed) != 1 || delta.Changed[0].ID != ids[0] || len(delta.Removed) != 0 {
+		t.Errorf("full=%v changed=%d removed=%d, want 全量且只剩 1 个任务", delta.Full, len(delta.Changed), len(delta.Removed))
+	}
+}
+
+func TestGetTasksDeltaDestroyedSession(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	before := env.addTasks(storeID, "五花肉")
+	env.scheduler.ApplyBatch(storeID.String(), BatchOp{StoreID: storeID.String(), ReplaceAll: true})
+
+	// 会话已销毁：全量空列表，客户端清空本地任务
+	delta := env.scheduler.GetTasksDelta(storeID.String(), before.Epoch, before.Revision, "")
+	if !delta.Full || len(delta.Changed) != 0 {
+		t.Errorf("full=%v changed=%d, want 全量空列表", delta.Full, len(delta.Changed))
+	}
+}
+
+func TestGetTasksDeltaSkipsUnchangedIntroPool(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	snapshot := env.waitReady(t, env.addTasks(storeID, "五花肉").SessionID)
+
+	delta := env.scheduler.GetTasksDelta(storeID.String(), snapshot.Epoch, snapshot.Revision, snapshot.IntroPoolVersion)
+	if delta.IntroPool != nil || delta.IntroPoolVersion != snapshot.IntroPoolVersion {
+		t.Errorf("开场白池版本一致时仍下发了开场白池: version=%s pool=%v", delta.IntroPoolVersion, delta.IntroPool)
+	}
+	if delta = env.scheduler.GetTasksDelta(storeID.String(), snapshot.Epoch, snapshot.Revision, ""); delta.IntroPool == nil {
+		t.Errorf("客户端没有开场白池时没有下发")
+	}
+}
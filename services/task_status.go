@@ -0,0 +1,56 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"log"
+	"time"
+)
+
+// TaskStatusEventData HAWKING_TASK_STATUS 消息体：任务每次状态跳转都会推送一条
+type TaskStatusEventData struct {
+	SessionID string              `json:"session_id"`
+	ProductID string              `json:"product_id"`
+	TaskID    string              `json:"task_id"`
+	From      models.TaskStatus   `json:"from"`
+	To        models.TaskStatus   `json:"to"`
+	At        time.Time           `json:"at"`
+	Reason    string              `json:"reason,omitempty"`
+	Product   *models.HawkingTask `json:"product"` // 跳转后的任务快照
+}
+
+// transitionLocked 按状态机规则切换任务状态，非法跳转会被拒绝并返回 nil
+// 调用方必须持有 sess.mu 写锁，并在释放锁之后把返回的事件交给 emitStatusEvents
+func (s *HawkingScheduler) transitionLocked(sess *HawkingSession, task *models.HawkingTask, to models.TaskStatus, reason string) *TaskStatusEventData {
+	from := task.Status
+	if !from.CanTransitionTo(to) {
+		log.Printf("⚠️ 非法的任务状态跳转 [%s]: %q -> %q", task.ProductID, from, to)
+		return nil
+	}
+
+	now := time.Now()
+	task.Status = to
+	task.StatusChangedAt = now
+	sess.touchLocked(task)
+
+	snapshot := *task
+	return &TaskStatusEventData{
+		SessionID: sess.ID,
+		ProductID: task.ProductID,
+		TaskID:    task.ID,
+		From:      from,
+		To:        to,
+		At:        now,
+		Reason:    reason,
+		Product:   &snapshot,
+	}
+}
+
+// emitStatusEvents 广播状态跳转事件，nil 会被跳过
+func (s *HawkingScheduler) emitStatusEvents(events ...*TaskStatusEventData) {
+	for _, event := range events {
+		if event == nil {
+			continue
+		}
+		s.broadcastToSession(event.SessionID, models.WSMessage{Type: "HAWKING_TASK_STATUS", Data: event})
+	}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"hawker-backend/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTransitionLocked(t *testing.T) {
+	env := newTestEnv(t)
+	storeID := uuid.New()
+	snapshot := env.waitReady(t, env.addTasks(storeID, "五花肉").SessionID)
+	if snapshot.Products[0].StatusChangedAt.IsZero() {
+		t.Errorf("快照里没有状态变化时间")
+	}
+
+	env.scheduler.sessionMu.RLock()
+	sess := env.scheduler.sessions[storeID.String()]
+	env.scheduler.sessionMu.RUnlock()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	task := sess.ActiveTasks[snapshot.Products[0].ID]
+
+	// 非法跳转被拒绝，状态和修订号都不变
+	revision := sess.Revision
+	if event := env.scheduler.transitionLocked(sess, task, models.TaskSynthesizing, "test"); event != nil {
+		t.Errorf("ready -> synthesizing 不应被允许: %+v", event)
+	}
+	if task.Status != models.TaskReady || sess.Revision != revision {
+		t.Errorf("非法跳转后 status=%s revision=%d, want ready %d", task.Status, sess.Revision, revision)
+	}
+
+	// 合法跳转返回事件，并推进修订号
+	event := env.scheduler.transitionLocked(sess, task, models.TaskSuperseded, "voice_changed")
+	if event == nil || event.From != models.TaskReady || event.To != models.TaskSuperseded || event.Reason != "voice_changed" {
+		t.Fatalf("ready -> superseded 事件 = %+v", event)
+	}
+	if event.Product.Status != models.TaskSuperseded || task.Revision != sess.Revision || sess.Revision != revision+1 {
+		t.Errorf("跳转后 status=%s task.revision=%d session.revision=%d", event.Product.Status, task.Revision, sess.Revision)
+	}
+}
@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"hawker-backend/conf"
 	"time"
 
@@ -34,3 +38,43 @@ func GenerateToken(ownerID uuid.UUID, cfg conf.AuthConfig) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(cfg.JWTSecret))
 }
+
+// ParseToken 校验 JWT 并取出其中的老板 ID
+func ParseToken(tokenString string, jwtKey string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// 1. 强制检查算法（推荐的安全做法）
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		// 2. 必须显式转换成 []byte
+		return []byte(jwtKey), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("token 无效")
+	}
+	return claims, nil
+}
+
+// 设备令牌前缀，与 JWT 区分开
+const DeviceTokenPrefix = "dev_"
+
+// GenerateDeviceToken 生成音箱等设备的长期令牌，返回明文（只展示一次）和入库用的哈希
+func GenerateDeviceToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := DeviceTokenPrefix + hex.EncodeToString(buf)
+	return token, HashDeviceToken(token), nil
+}
+
+// HashDeviceToken 数据库只保存令牌的哈希，泄露数据库也拿不到可用的令牌
+func HashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}